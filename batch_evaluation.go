@@ -0,0 +1,178 @@
+package kameleoon
+
+import (
+	"context"
+	"runtime"
+	"sync"
+
+	"github.com/Kameleoon/client-go/v3/logging"
+	"github.com/Kameleoon/client-go/v3/storage"
+	"github.com/Kameleoon/client-go/v3/types"
+	"github.com/Kameleoon/client-go/v3/utils"
+)
+
+type EvaluateBatchOptParams struct {
+	track          bool
+	createVisitors bool
+	workers        int
+	onResult       func(types.BatchVisitorResult)
+}
+
+func NewEvaluateBatchOptParams() EvaluateBatchOptParams {
+	return EvaluateBatchOptParams{}
+}
+
+// Track sets whether the evaluations are tracked the same way as by GetVariations. Tracking creates visitors.
+func (p EvaluateBatchOptParams) Track(value bool) EvaluateBatchOptParams {
+	p.track = value
+	return p
+}
+
+// CreateVisitors sets whether the visitors are created to save their assigned variations without tracking.
+func (p EvaluateBatchOptParams) CreateVisitors(value bool) EvaluateBatchOptParams {
+	p.createVisitors = value
+	return p
+}
+
+// Workers sets the number of goroutines evaluating the visitors, GOMAXPROCS by default.
+func (p EvaluateBatchOptParams) Workers(value int) EvaluateBatchOptParams {
+	p.workers = value
+	return p
+}
+
+// OnResult sets the callback which receives the result of every visitor as soon as it is evaluated.
+// The result matrix is not collected then. The callback is called from the worker goroutines,
+// so it must be safe for concurrent use.
+func (p EvaluateBatchOptParams) OnResult(callback func(types.BatchVisitorResult)) EvaluateBatchOptParams {
+	p.onResult = callback
+	return p
+}
+
+// IsTracked returns the value set with Track.
+func (p EvaluateBatchOptParams) IsTracked() bool {
+	return p.track
+}
+
+// ResultCallback returns the callback set with OnResult.
+func (p EvaluateBatchOptParams) ResultCallback() func(types.BatchVisitorResult) {
+	return p.onResult
+}
+
+func (c *kameleoonClient) EvaluateBatch(
+	ctx context.Context, visitorCodes []string, featureKeys []string, params ...EvaluateBatchOptParams,
+) (result *types.BatchEvaluationResult, err error) {
+	logging.Info(
+		"CALL: kameleoonClient.EvaluateBatch(ctx, len(visitorCodes): %s, featureKeys: %s, params: %s)",
+		len(visitorCodes), featureKeys, params,
+	)
+	defer func() {
+		logging.Info(
+			"RETURN: kameleoonClient.EvaluateBatch(ctx, len(visitorCodes): %s, featureKeys: %s, params: %s) -> "+
+				"(result, error: %s)", len(visitorCodes), featureKeys, params, err,
+		)
+	}()
+	p := NewEvaluateBatchOptParams()
+	if len(params) > 0 {
+		p = params[0]
+	}
+	if p.workers <= 0 {
+		p.workers = runtime.GOMAXPROCS(0)
+	}
+	// All the visitors are evaluated against the same configuration, even if it is updated meanwhile
+	dataFile := c.dataManager.DataFile()
+	var featureFlags []types.IFeatureFlag
+	if featureFlags, featureKeys, err = collectBatchFeatureFlags(dataFile, featureKeys); err != nil {
+		return
+	}
+	result = &types.BatchEvaluationResult{VisitorCodes: visitorCodes, FeatureKeys: featureKeys}
+	onResult := p.onResult
+	if onResult == nil {
+		result.VariationKeys = make([][]string, len(visitorCodes))
+		result.Errors = make([]error, len(visitorCodes))
+		onResult = func(r types.BatchVisitorResult) {
+			result.VariationKeys[r.Index] = r.VariationKeys
+			result.Errors[r.Index] = r.Err
+		}
+	}
+	evaluator := newVariationEvaluator(dataFile, c.visitorManager)
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < p.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indices {
+				onResult(c.evaluateBatchVisitor(evaluator, index, visitorCodes[index], featureFlags, p))
+			}
+		}()
+	}
+loop:
+	for index := range visitorCodes {
+		select {
+		case indices <- index:
+		case <-ctx.Done():
+			break loop
+		}
+	}
+	close(indices)
+	wg.Wait()
+	err = ctx.Err()
+	return
+}
+
+func collectBatchFeatureFlags(
+	dataFile types.IDataFile, featureKeys []string,
+) ([]types.IFeatureFlag, []string, error) {
+	var featureFlags []types.IFeatureFlag
+	if len(featureKeys) == 0 {
+		for _, ff := range dataFile.GetOrderedFeatureFlags() {
+			if ff.GetEnvironmentEnabled() {
+				featureFlags = append(featureFlags, ff)
+				featureKeys = append(featureKeys, ff.GetFeatureKey())
+			}
+		}
+		return featureFlags, featureKeys, nil
+	}
+	featureFlags = make([]types.IFeatureFlag, len(featureKeys))
+	for i, featureKey := range featureKeys {
+		ff, err := dataFile.GetFeatureFlag(featureKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		featureFlags[i] = ff
+	}
+	return featureFlags, featureKeys, nil
+}
+
+func (c *kameleoonClient) evaluateBatchVisitor(
+	evaluator *variationEvaluator, index int, visitorCode string, featureFlags []types.IFeatureFlag,
+	p EvaluateBatchOptParams,
+) types.BatchVisitorResult {
+	result := types.BatchVisitorResult{Index: index, VisitorCode: visitorCode}
+	if result.Err = utils.ValidateVisitorCode(visitorCode); result.Err != nil {
+		return result
+	}
+	save := p.track || p.createVisitors
+	// The visitor is created up front if the variations are saved, so every evaluation sees the saved ones
+	var visitor storage.Visitor
+	if save {
+		visitor = evaluator.visitorManager.GetOrCreateVisitor(visitorCode)
+	} else {
+		visitor = evaluator.visitorManager.GetVisitor(visitorCode)
+	}
+	result.VariationKeys = make([]string, len(featureFlags))
+	for i, ff := range featureFlags {
+		evalExp, err := evaluator.evaluate(visitor, visitorCode, ff, p.track, save, nil)
+		if err != nil {
+			if result.Err == nil {
+				result.Err = err
+			}
+			continue
+		}
+		result.VariationKeys[i] = evaluator.calculateVariationKey(evalExp, ff.GetDefaultVariationKey())
+	}
+	if p.track {
+		c.trackingManager.AddVisitorCode(visitorCode)
+	}
+	return result
+}
@@ -0,0 +1,122 @@
+package configuration
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/Kameleoon/client-go/v3/types"
+)
+
+// ConfigurationChangeSource is the way a configuration was obtained.
+type ConfigurationChangeSource string
+
+const (
+	// ConfigurationChangeSourcePoll is a regular fetch: the initial one, a periodic one or a fallback one.
+	ConfigurationChangeSourcePoll      ConfigurationChangeSource = "poll"
+	ConfigurationChangeSourceSse       ConfigurationChangeSource = "sse"
+	ConfigurationChangeSourceBootstrap ConfigurationChangeSource = "bootstrap"
+	ConfigurationChangeSourceSnapshot  ConfigurationChangeSource = "snapshot"
+)
+
+type FeatureFlagChangeKind string
+
+const (
+	FeatureFlagAdded    FeatureFlagChangeKind = "added"
+	FeatureFlagRemoved  FeatureFlagChangeKind = "removed"
+	FeatureFlagModified FeatureFlagChangeKind = "modified"
+)
+
+// FeatureFlagChange describes how a feature flag differs from its previous version.
+// The *Changed fields are only set for modified feature flags.
+type FeatureFlagChange struct {
+	FeatureKey                string
+	Kind                      FeatureFlagChangeKind
+	EnvironmentEnabledChanged bool
+	// RulesChanged covers added, removed and reordered rules, their type, exposition, segment and variations
+	RulesChanged bool
+	// VariationsChanged covers the variations of the feature flag, their variables and the default variation
+	VariationsChanged bool
+	// OtherChanged covers the rest, like the mutually exclusive group or the bucketing custom data
+	OtherChanged bool
+}
+
+func (c FeatureFlagChange) String() string {
+	return fmt.Sprintf(
+		"FeatureFlagChange{FeatureKey:'%v',Kind:%v,EnvironmentEnabledChanged:%v,RulesChanged:%v,"+
+			"VariationsChanged:%v,OtherChanged:%v}", c.FeatureKey, c.Kind, c.EnvironmentEnabledChanged,
+		c.RulesChanged, c.VariationsChanged, c.OtherChanged,
+	)
+}
+
+// ConfigurationChange is delivered to the subscribers every time a new configuration is applied.
+// FeatureFlags is empty if no feature flag differs, e.g. if only the settings were changed.
+type ConfigurationChange struct {
+	Source          ConfigurationChangeSource
+	OldLastModified string
+	NewLastModified string
+	// FeatureFlags are sorted by feature key
+	FeatureFlags []FeatureFlagChange
+}
+
+func (c ConfigurationChange) String() string {
+	return fmt.Sprintf(
+		"ConfigurationChange{Source:%v,OldLastModified:'%v',NewLastModified:'%v',FeatureFlags:%v}",
+		c.Source, c.OldLastModified, c.NewLastModified, c.FeatureFlags,
+	)
+}
+
+func diffFeatureFlags(oldDataFile, newDataFile types.IDataFile) []FeatureFlagChange {
+	oldFFs := featureFlagsByKey(oldDataFile)
+	newFFs := featureFlagsByKey(newDataFile)
+	var changes []FeatureFlagChange
+	for key, newFF := range newFFs {
+		oldFF, exists := oldFFs[key]
+		if !exists {
+			changes = append(changes, FeatureFlagChange{FeatureKey: key, Kind: FeatureFlagAdded})
+			continue
+		}
+		change := FeatureFlagChange{
+			FeatureKey:                key,
+			Kind:                      FeatureFlagModified,
+			EnvironmentEnabledChanged: oldFF.GetEnvironmentEnabled() != newFF.GetEnvironmentEnabled(),
+			RulesChanged:              !equalRules(oldFF.GetRules(), newFF.GetRules()),
+			VariationsChanged: (oldFF.GetDefaultVariationKey() != newFF.GetDefaultVariationKey()) ||
+				!reflect.DeepEqual(oldFF.GetVariations(), newFF.GetVariations()),
+			OtherChanged: (oldFF.GetId() != newFF.GetId()) || (oldFF.GetMEGroupName() != newFF.GetMEGroupName()) ||
+				!reflect.DeepEqual(oldFF.GetBucketingCustomDataIndex(), newFF.GetBucketingCustomDataIndex()),
+		}
+		if change.EnvironmentEnabledChanged || change.RulesChanged || change.VariationsChanged || change.OtherChanged {
+			changes = append(changes, change)
+		}
+	}
+	for key := range oldFFs {
+		if _, exists := newFFs[key]; !exists {
+			changes = append(changes, FeatureFlagChange{FeatureKey: key, Kind: FeatureFlagRemoved})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].FeatureKey < changes[j].FeatureKey })
+	return changes
+}
+
+func featureFlagsByKey(dataFile types.IDataFile) map[string]types.IFeatureFlag {
+	ffs := make(map[string]types.IFeatureFlag)
+	if dataFile != nil {
+		for _, ff := range dataFile.GetOrderedFeatureFlags() {
+			ffs[ff.GetFeatureKey()] = ff
+		}
+	}
+	return ffs
+}
+
+func equalRules(oldRules, newRules []types.IRule) bool {
+	if len(oldRules) != len(newRules) {
+		return false
+	}
+	for i := range oldRules {
+		if !reflect.DeepEqual(oldRules[i].GetRuleBase(), newRules[i].GetRuleBase()) {
+			return false
+		}
+	}
+	return true
+}
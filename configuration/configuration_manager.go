@@ -1,6 +1,8 @@
 package configuration
 
 import (
+	"context"
+	"errors"
 	"sync"
 	"time"
 
@@ -15,37 +17,64 @@ import (
 // Not thread-safe
 type ConfigurationManager interface {
 	Start() error
+	// Bootstrap applies a raw configuration in the same JSON format as the fetched one.
+	// It is meant to be used before Start, the configuration is replaced once a fetch succeeds.
+	Bootstrap(rawConfiguration []byte) error
+	// RestoreSnapshot applies the configuration persisted by the snapshot store if there is any.
+	// It is meant to be used before Start, so the first fetch is conditional on the stored Last-Modified.
+	RestoreSnapshot() error
 	OnUpdateConfiguration(handler func())
+	// SubscribeChanges registers the handler called with every applied configuration.
+	// The last applied change is replayed to the handler, so the changes applied before the subscription
+	// (e.g. the bootstrap or snapshot ones) are not missed.
+	// The handlers are called one by one, so a slow handler delays the others.
+	SubscribeChanges(handler func(ConfigurationChange)) (unsubscribe func())
 	TryFetch(ts int64) (bool, error)
+	// Close stops the configuration polling and streaming for good.
+	Close()
 }
 
 type configurationManagerImpl struct {
 	dataManager    data.DataManager
 	networkManager network.NetworkManager
 	sseClient      realtime.SseClient
+	snapshotStore  SnapshotStore
 
 	pollingUpdateInterval time.Duration
 	environment           string
+	reconnectPolicy       realtime.ReconnectPolicy
 
 	updateConfigurationHandler func()
+	subscribersMx              sync.Mutex
+	subscribers                map[uint64]*changeSubscriber
+	nextSubscriberId           uint64
+	changeSeq                  uint64
+	lastChange                 *ConfigurationChange
 
 	mx                           sync.Mutex
+	closed                       bool
+	streamDown                   bool // the real-time service is unable to reconnect, so polling is used
 	lastTS                       int64
 	pollingConfigurationTicker   *time.Ticker
-	pollingConfigurationStopChan chan bool
+	pollingConfigurationStopChan chan struct{}
 	realTimeConfigurationService *realtime.RealTimeConfigurationService
 	realTimeUpdateChan           chan realtime.RealTimeEvent
 }
 
+// snapshotStore is optional and may be nil
 func NewConfigurationManager(dataManager data.DataManager, networkManager network.NetworkManager,
-	sseClient realtime.SseClient, pollingUpdateInterval time.Duration, environment string,
+	sseClient realtime.SseClient, snapshotStore SnapshotStore, pollingUpdateInterval time.Duration,
+	environment string, reconnectPolicy realtime.ReconnectPolicy,
 ) *configurationManagerImpl {
 	return &configurationManagerImpl{
 		dataManager:           dataManager,
 		networkManager:        networkManager,
 		sseClient:             sseClient,
+		snapshotStore:         snapshotStore,
 		pollingUpdateInterval: pollingUpdateInterval,
 		environment:           environment,
+		reconnectPolicy:       reconnectPolicy,
+		subscribers:           make(map[uint64]*changeSubscriber),
 	}
 }
 
@@ -53,18 +82,125 @@ func (cm *configurationManagerImpl) Start() error {
 	logging.Debug("CALL: configurationManagerImpl.Start()")
 	ok, err := cm.TryFetch(-1)
 	if !ok {
-		cm.startPollingConfigurationTickerIfNeeded()
+		cm.mx.Lock()
+		if !cm.closed {
+			cm.startPollingConfigurationTickerIfNeeded()
+		}
+		cm.mx.Unlock()
 	}
 	logging.Debug("RETURN: configurationManagerImpl.Start() -> (error: %s)", err)
 	return err
 }
 
+func (cm *configurationManagerImpl) Bootstrap(rawConfiguration []byte) error {
+	logging.Debug("CALL: configurationManagerImpl.Bootstrap(rawConfiguration)")
+	// Empty last modified makes the first fetch unconditional, so the bootstrap configuration gets replaced
+	err := cm.applyRawConfiguration(rawConfiguration, "", ConfigurationChangeSourceBootstrap)
+	if err == nil {
+		logging.Info("Configuration bootstrapped")
+	} else {
+		logging.Error("Failed to parse bootstrap configuration: %s", err)
+	}
+	logging.Debug("RETURN: configurationManagerImpl.Bootstrap(rawConfiguration) -> (error: %s)", err)
+	return err
+}
+
+func (cm *configurationManagerImpl) RestoreSnapshot() error {
+	logging.Debug("CALL: configurationManagerImpl.RestoreSnapshot()")
+	var err error
+	if cm.snapshotStore == nil {
+		err = errors.New("configuration snapshot store is not set")
+	} else {
+		var rawConfiguration []byte
+		var lastModified string
+		if rawConfiguration, lastModified, err = cm.snapshotStore.Load(); err == nil {
+			err = cm.applyRawConfiguration(rawConfiguration, lastModified, ConfigurationChangeSourceSnapshot)
+		}
+		if err == nil {
+			logging.Info("Configuration restored from snapshot (lastModified: %s)", lastModified)
+		} else {
+			logging.Warning("Failed to restore configuration snapshot: %s", err)
+		}
+	}
+	logging.Debug("RETURN: configurationManagerImpl.RestoreSnapshot() -> (error: %s)", err)
+	return err
+}
+
+func (cm *configurationManagerImpl) applyRawConfiguration(
+	rawConfiguration []byte, lastModified string, source ConfigurationChangeSource,
+) error {
+	var configuration Configuration
+	err := json.Unmarshal(rawConfiguration, &configuration)
+	if err == nil {
+		cm.publishChange(cm.updateDataFile(NewDataFile(configuration, lastModified, cm.environment), source))
+	}
+	return err
+}
+
 func (cm *configurationManagerImpl) OnUpdateConfiguration(handler func()) {
 	logging.Debug("CALL: configurationManagerImpl.OnUpdateConfiguration()")
 	cm.updateConfigurationHandler = handler
 	logging.Debug("RETURN: configurationManagerImpl.OnUpdateConfiguration()")
 }
 
+func (cm *configurationManagerImpl) SubscribeChanges(handler func(ConfigurationChange)) func() {
+	logging.Debug("CALL: configurationManagerImpl.SubscribeChanges()")
+	subscriber := &changeSubscriber{handler: handler}
+	cm.subscribersMx.Lock()
+	id := cm.nextSubscriberId
+	cm.nextSubscriberId++
+	cm.subscribers[id] = subscriber
+	seq, lastChange := cm.changeSeq, cm.lastChange
+	// Locked before the subscriber becomes visible, so the changes published meanwhile follow the replayed one
+	subscriber.mx.Lock()
+	cm.subscribersMx.Unlock()
+	if lastChange != nil {
+		subscriber.seq = seq
+		handler(*lastChange)
+	}
+	subscriber.mx.Unlock()
+	unsubscribe := func() {
+		cm.subscribersMx.Lock()
+		delete(cm.subscribers, id)
+		cm.subscribersMx.Unlock()
+	}
+	logging.Debug("RETURN: configurationManagerImpl.SubscribeChanges()")
+	return unsubscribe
+}
+
+func (cm *configurationManagerImpl) publishChange(change ConfigurationChange) {
+	logging.Info("Configuration changed: %s", change)
+	cm.subscribersMx.Lock()
+	cm.changeSeq++
+	seq := cm.changeSeq
+	cm.lastChange = &change
+	subscribers := make([]*changeSubscriber, 0, len(cm.subscribers))
+	for _, subscriber := range cm.subscribers {
+		subscribers = append(subscribers, subscriber)
+	}
+	cm.subscribersMx.Unlock()
+	for _, subscriber := range subscribers {
+		subscriber.deliver(seq, change)
+	}
+}
+
+type changeSubscriber struct {
+	mx      sync.Mutex
+	handler func(ConfigurationChange)
+	seq     uint64 // of the last delivered change
+}
+
+// deliver calls the handler unless a later change has been delivered already.
+func (s *changeSubscriber) deliver(seq uint64, change ConfigurationChange) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if seq <= s.seq {
+		return
+	}
+	s.seq = seq
+	s.handler(change)
+}
+
 func (cm *configurationManagerImpl) TryFetch(ts int64) (bool, error) {
 	logging.Debug("CALL: configurationManagerImpl.tryFetch(ts: %s)", ts)
 	if (ts != -1) && (ts < cm.lastTS) {
@@ -74,7 +210,9 @@ func (cm *configurationManagerImpl) TryFetch(ts int64) (bool, error) {
 	if err := cm.fetchConfig(ts); err != nil {
 		logging.Error("Fetch failed: %s", err)
 		if cm.dataManager.DataFile().Settings().RealTimeUpdate() {
+			cm.mx.Lock()
 			cm.manageConfigurationUpdate(false)
+			cm.mx.Unlock()
 			logging.Warning("Switched to polling mode due to failed fetch")
 		}
 		logging.Debug("RETURN: configurationManagerImpl.tryFetch(ts: %s) -> (isFetched: false, error: %s)", ts, err)
@@ -96,13 +234,19 @@ func (cm *configurationManagerImpl) TryFetch(ts int64) (bool, error) {
 
 func (cm *configurationManagerImpl) fetchConfig(ts int64) error {
 	logging.Debug("CALL: configurationManagerImpl.fetchConfig(ts: %s)", ts)
-	clientConfig, hasClientConfig, lastModified, err := cm.requestClientConfig(ts)
+	clientConfig, rawClientConfig, lastModified, err := cm.requestClientConfig(ts)
 	if err == nil {
-		if hasClientConfig {
-			cm.updateDataFile(NewDataFile(clientConfig, lastModified, cm.environment))
+		if len(rawClientConfig) > 0 {
+			source := ConfigurationChangeSourcePoll
+			if ts != -1 {
+				source = ConfigurationChangeSourceSse
+			}
+			change := cm.updateDataFile(NewDataFile(clientConfig, lastModified, cm.environment), source)
+			cm.saveSnapshot(rawClientConfig, lastModified)
 			if (ts != -1) && (cm.updateConfigurationHandler != nil) {
 				cm.updateConfigurationHandler()
 			}
+			cm.publishChange(change)
 		}
 	} else {
 		logging.Error("Failed to fetch: %s", err)
@@ -111,16 +255,36 @@ func (cm *configurationManagerImpl) fetchConfig(ts int64) error {
 	return err
 }
 
-func (cm *configurationManagerImpl) updateDataFile(df *DataFile) {
-	logging.Debug("CALL: configurationManagerImpl.updateDataFile(df: %s)", df)
+func (cm *configurationManagerImpl) updateDataFile(df *DataFile, source ConfigurationChangeSource) ConfigurationChange {
+	logging.Debug("CALL: configurationManagerImpl.updateDataFile(df: %s, source: %s)", df, source)
 	cm.mx.Lock()
 	defer cm.mx.Unlock()
+	oldDataFile := cm.dataManager.DataFile()
+	change := ConfigurationChange{
+		Source:          source,
+		NewLastModified: df.LastModified(),
+		FeatureFlags:    diffFeatureFlags(oldDataFile, df),
+	}
+	if oldDataFile != nil {
+		change.OldLastModified = oldDataFile.LastModified()
+	}
 	cm.dataManager.SetDataFile(df)
 	cm.networkManager.GetUrlProvider().ApplyDataApiDomain(df.Settings().DataApiDomain())
-	logging.Debug("RETURN: configurationManagerImpl.updateDataFile(df: %s)", df)
+	logging.Debug("RETURN: configurationManagerImpl.updateDataFile(df: %s, source: %s) -> (change: %s)",
+		df, source, change)
+	return change
 }
 
-func (cm *configurationManagerImpl) requestClientConfig(ts int64) (Configuration, bool, string, error) {
+func (cm *configurationManagerImpl) saveSnapshot(rawConfiguration []byte, lastModified string) {
+	if cm.snapshotStore == nil {
+		return
+	}
+	if err := cm.snapshotStore.Save(rawConfiguration, lastModified); err != nil {
+		logging.Error("Failed to save configuration snapshot: %s", err)
+	}
+}
+
+func (cm *configurationManagerImpl) requestClientConfig(ts int64) (Configuration, []byte, string, error) {
 	logging.Debug("CALL: configurationManagerImpl.requestClientConfig(ts: %s)", ts)
 	if ts == -1 {
 		logging.Info("Fetching configuration")
@@ -129,7 +293,9 @@ func (cm *configurationManagerImpl) requestClientConfig(ts int64) (Configuration
 	}
 	var campaigns Configuration
 
-	fetchedConfiguration, err := cm.networkManager.FetchConfiguration(ts, cm.dataManager.DataFile().LastModified())
+	fetchedConfiguration, err := cm.networkManager.FetchConfiguration(
+		context.Background(), ts, cm.dataManager.DataFile().LastModified(),
+	)
 	if (err == nil) && (len(fetchedConfiguration.Configuration) > 0) {
 		err = json.Unmarshal(fetchedConfiguration.Configuration, &campaigns)
 	}
@@ -140,7 +306,7 @@ func (cm *configurationManagerImpl) requestClientConfig(ts int64) (Configuration
 	}
 	logging.Debug("RETURN: configurationManagerImpl.requestClientConfig(ts: %s) -> (campaigns: %s, error: %s)",
 		ts, campaigns, err)
-	return campaigns, len(fetchedConfiguration.Configuration) > 0, fetchedConfiguration.LastModified, err
+	return campaigns, fetchedConfiguration.Configuration, fetchedConfiguration.LastModified, err
 }
 
 func (cm *configurationManagerImpl) startPollingConfigurationTickerIfNeeded() {
@@ -148,23 +314,25 @@ func (cm *configurationManagerImpl) startPollingConfigurationTickerIfNeeded() {
 		return
 	}
 	logging.Debug("CALL: configurationManagerImpl.startPollingConfigurationTickerIfNeeded()")
-	cm.pollingConfigurationStopChan = make(chan bool, 1)
-	cm.pollingConfigurationTicker = time.NewTicker(cm.pollingUpdateInterval)
+	// Every poller has its own ticker and stop channel, so a stopped poller never affects a newer one
+	stopChan := make(chan struct{})
+	ticker := time.NewTicker(cm.pollingUpdateInterval)
+	cm.pollingConfigurationStopChan = stopChan
+	cm.pollingConfigurationTicker = ticker
 	go func() {
-		for halt := false; !halt; {
+		for {
 			select {
-			case halt = <-cm.pollingConfigurationStopChan:
+			case <-stopChan:
+				return
 			default:
-				select {
-				case halt = <-cm.pollingConfigurationStopChan:
-				case <-cm.pollingConfigurationTicker.C:
-					cm.TryFetch(-1)
-				}
+			}
+			select {
+			case <-stopChan:
+				return
+			case <-ticker.C:
+				cm.TryFetch(-1)
 			}
 		}
-		cm.mx.Lock()
-		cm.pollingConfigurationTicker = nil
-		cm.mx.Unlock()
 	}()
 	logging.Info("Configuration polling is started")
 	logging.Debug("RETURN: configurationManagerImpl.startPollingConfigurationTickerIfNeeded()")
@@ -173,8 +341,11 @@ func (cm *configurationManagerImpl) startPollingConfigurationTickerIfNeeded() {
 func (cm *configurationManagerImpl) stopPollingConfigurationTickerIfNeeded() {
 	logging.Debug("CALL: configurationManagerImpl.stopPollingConfigurationTickerIfNeeded()")
 	if cm.pollingConfigurationTicker != nil {
-		cm.pollingConfigurationStopChan <- true
+		// Closing does not block, so the poller may be waiting for the mutex held by the caller
+		close(cm.pollingConfigurationStopChan)
 		cm.pollingConfigurationTicker.Stop()
+		cm.pollingConfigurationStopChan = nil
+		cm.pollingConfigurationTicker = nil
 		logging.Info("Configuration polling is stopped")
 	}
 	logging.Debug("RETURN: configurationManagerImpl.stopPollingConfigurationTickerIfNeeded()")
@@ -186,8 +357,9 @@ func (cm *configurationManagerImpl) startRealTimeConfigurationServiceIfNeeded()
 	}
 	logging.Debug("CALL: configurationManagerImpl.startRealTimeConfigurationServiceIfNeeded()")
 	cm.realTimeUpdateChan = make(chan realtime.RealTimeEvent, 16)
-	cm.realTimeConfigurationService = realtime.NewRealTimeConfigurationService(
-		cm.networkManager.GetUrlProvider().MakeRealTimeUrl(), cm.realTimeUpdateChan, cm.sseClient)
+	cm.realTimeConfigurationService = realtime.NewRealTimeConfigurationServiceWithPolicy(
+		cm.networkManager.GetUrlProvider().MakeRealTimeUrl(), cm.realTimeUpdateChan, cm.sseClient,
+		cm.reconnectPolicy, cm.onStreamingStateChange)
 	go func() {
 		for realTimeEvent := range cm.realTimeUpdateChan {
 			cm.TryFetch(realTimeEvent.TimeStamp)
@@ -202,14 +374,56 @@ func (cm *configurationManagerImpl) stopRealTimeConfigurationServiceIfNeeded() {
 	if cm.realTimeConfigurationService != nil {
 		cm.realTimeConfigurationService.Close()
 		cm.realTimeConfigurationService = nil
+		cm.streamDown = false
 		logging.Info("Configuration streaming is stopped")
 	}
 	logging.Debug("RETURN: configurationManagerImpl.stopRealTimeConfigurationServiceIfNeeded()")
 }
 
+// onStreamingStateChange polls the configuration while the real-time service is unable to reconnect.
+// The service keeps reconnecting in the background, so the polling is stopped once the stream is back.
+func (cm *configurationManagerImpl) onStreamingStateChange(streaming bool) {
+	logging.Debug("CALL: configurationManagerImpl.onStreamingStateChange(streaming: %s)", streaming)
+	cm.mx.Lock()
+	// The real-time service may have been stopped meanwhile
+	active := !cm.closed && (cm.realTimeConfigurationService != nil)
+	if active {
+		cm.streamDown = !streaming
+		if streaming {
+			cm.stopPollingConfigurationTickerIfNeeded()
+		} else {
+			cm.startPollingConfigurationTickerIfNeeded()
+		}
+	}
+	cm.mx.Unlock()
+	if active && streaming {
+		// Updates could have been missed between the last poll and the reconnection
+		go cm.TryFetch(-1)
+	}
+	logging.Debug("RETURN: configurationManagerImpl.onStreamingStateChange(streaming: %s)", streaming)
+}
+
+func (cm *configurationManagerImpl) Close() {
+	logging.Debug("CALL: configurationManagerImpl.Close()")
+	cm.mx.Lock()
+	defer cm.mx.Unlock()
+	if !cm.closed {
+		cm.closed = true
+		cm.stopPollingConfigurationTickerIfNeeded()
+		cm.stopRealTimeConfigurationServiceIfNeeded()
+	}
+	logging.Debug("RETURN: configurationManagerImpl.Close()")
+}
+
+// Must be called with the mutex locked
 func (cm *configurationManagerImpl) manageConfigurationUpdate(realTimeUpdate bool) {
+	if cm.closed {
+		return
+	}
 	if realTimeUpdate {
-		cm.stopPollingConfigurationTickerIfNeeded()
+		if !cm.streamDown {
+			cm.stopPollingConfigurationTickerIfNeeded()
+		}
 		cm.startRealTimeConfigurationServiceIfNeeded()
 	} else {
 		cm.stopRealTimeConfigurationServiceIfNeeded()
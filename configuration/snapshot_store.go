@@ -0,0 +1,121 @@
+package configuration
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/Kameleoon/client-go/v3/logging"
+	"github.com/segmentio/encoding/json"
+)
+
+// SnapshotStore persists the last successfully fetched configuration.
+type SnapshotStore interface {
+	// Load returns the stored raw configuration and its Last-Modified value.
+	Load() (rawConfiguration []byte, lastModified string, err error)
+	// Save replaces the stored configuration.
+	Save(rawConfiguration []byte, lastModified string) error
+}
+
+type snapshotEnvelope struct {
+	LastModified  string          `json:"lastModified"`
+	Checksum      string          `json:"checksum"`
+	Configuration json.RawMessage `json:"configuration"`
+}
+
+// FileSnapshotStore stores the configuration snapshot as a JSON file.
+// The file is replaced atomically and carries a SHA-256 checksum of the configuration,
+// so a partially written or corrupted snapshot is detected on load.
+type FileSnapshotStore struct {
+	dir      string
+	fileName string
+}
+
+func NewFileSnapshotStore(dir string, siteCode string, environment string) *FileSnapshotStore {
+	fileName := "kameleoon_configuration_" + siteCode
+	if len(environment) > 0 {
+		fileName += "_" + environment
+	}
+	store := &FileSnapshotStore{dir: dir, fileName: fileName + ".json"}
+	logging.Debug("CALL/RETURN: NewFileSnapshotStore(dir: %s, siteCode: %s, environment: %s) -> (store)",
+		dir, siteCode, environment)
+	return store
+}
+
+func (s *FileSnapshotStore) path() string {
+	return filepath.Join(s.dir, s.fileName)
+}
+
+func (s *FileSnapshotStore) Load() ([]byte, string, error) {
+	logging.Debug("CALL: FileSnapshotStore.Load()")
+	var envelope snapshotEnvelope
+	content, err := os.ReadFile(s.path())
+	if err == nil {
+		err = json.Unmarshal(content, &envelope)
+	}
+	if (err == nil) && (computeChecksum(envelope.Configuration) != envelope.Checksum) {
+		err = errors.New("configuration snapshot checksum mismatch")
+	}
+	if err != nil {
+		envelope = snapshotEnvelope{}
+	}
+	logging.Debug("RETURN: FileSnapshotStore.Load() -> (lastModified: %s, error: %s)", envelope.LastModified, err)
+	return envelope.Configuration, envelope.LastModified, err
+}
+
+func (s *FileSnapshotStore) Save(rawConfiguration []byte, lastModified string) (err error) {
+	logging.Debug("CALL: FileSnapshotStore.Save(rawConfiguration, lastModified: %s)", lastModified)
+	defer func() {
+		logging.Debug("RETURN: FileSnapshotStore.Save(rawConfiguration, lastModified: %s) -> (error: %s)",
+			lastModified, err)
+	}()
+	// The configuration is compacted upfront as marshalling compacts raw messages anyway, and HTML escaping
+	// is disabled for the same reason, otherwise the checksum would not match the stored bytes.
+	var compacted bytes.Buffer
+	if err = json.Compact(&compacted, rawConfiguration); err != nil {
+		return
+	}
+	var content bytes.Buffer
+	encoder := json.NewEncoder(&content)
+	encoder.SetEscapeHTML(false)
+	err = encoder.Encode(snapshotEnvelope{
+		LastModified:  lastModified,
+		Checksum:      computeChecksum(compacted.Bytes()),
+		Configuration: compacted.Bytes(),
+	})
+	if err != nil {
+		return
+	}
+	if err = os.MkdirAll(s.dir, 0o755); err != nil {
+		return
+	}
+	return writeFileAtomically(s.path(), content.Bytes())
+}
+
+func writeFileAtomically(path string, content []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(content); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+func computeChecksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
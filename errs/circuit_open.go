@@ -0,0 +1,14 @@
+package errs
+
+import "fmt"
+
+// CircuitOpen is returned instead of making a request while the circuit breaker of the host is open.
+type CircuitOpen struct {
+	KameleoonError
+	Host string
+}
+
+func NewCircuitOpen(host string) *CircuitOpen {
+	msg := fmt.Sprintf("Circuit breaker for %s is open, the request is not sent", host)
+	return &CircuitOpen{KameleoonError: NewKameleoonError(msg), Host: host}
+}
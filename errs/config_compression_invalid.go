@@ -0,0 +1,9 @@
+package errs
+
+type ConfigCompressionInvalid struct {
+	ConfigError
+}
+
+func NewConfigCompressionInvalid(msg string) *ConfigCompressionInvalid {
+	return &ConfigCompressionInvalid{NewConfigError(msg)}
+}
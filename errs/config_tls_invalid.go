@@ -0,0 +1,9 @@
+package errs
+
+type ConfigTLSInvalid struct {
+	ConfigError
+}
+
+func NewConfigTLSInvalid(msg string) *ConfigTLSInvalid {
+	return &ConfigTLSInvalid{NewConfigError(msg)}
+}
@@ -0,0 +1,12 @@
+package errs
+
+import "fmt"
+
+type OfflineMode struct {
+	KameleoonError
+}
+
+func NewOfflineMode(operation string) *OfflineMode {
+	msg := fmt.Sprintf("%s is not available in offline mode", operation)
+	return &OfflineMode{NewKameleoonError(msg)}
+}
@@ -0,0 +1,12 @@
+package errs
+
+import "fmt"
+
+type SegmentNotFound struct {
+	KameleoonError
+}
+
+func NewSegmentNotFound(segmentId int) *SegmentNotFound {
+	msg := fmt.Sprintf("Segment %d is not found", segmentId)
+	return &SegmentNotFound{NewKameleoonError(msg)}
+}
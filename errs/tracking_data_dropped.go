@@ -0,0 +1,17 @@
+package errs
+
+import "fmt"
+
+// TrackingDataDropped is returned on close when some of the tracking data could not be delivered.
+type TrackingDataDropped struct {
+	KameleoonError
+	Count int
+}
+
+func NewTrackingDataDropped(count int, cause error) *TrackingDataDropped {
+	msg := fmt.Sprintf("%d tracking data were dropped on close", count)
+	if cause != nil {
+		msg += ": " + cause.Error()
+	}
+	return &TrackingDataDropped{KameleoonError: NewKameleoonError(msg), Count: count}
+}
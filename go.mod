@@ -5,27 +5,19 @@ go 1.18
 require (
 	github.com/cristalhq/aconfig v0.13.6
 	github.com/cristalhq/aconfig/aconfigyaml v0.12.0
+	github.com/klauspost/compress v1.15.0
 	github.com/orcaman/concurrent-map/v2 v2.0.1
 	github.com/segmentio/encoding v0.2.23
-	github.com/stretchr/testify v1.7.5
-	github.com/subchord/go-sse v1.0.7
 	github.com/valyala/fasthttp v1.34.0
 	golang.org/x/exp v0.0.0-20240529005216-23cca8864a10
 )
 
 require (
 	github.com/andybalholm/brotli v1.0.4 // indirect
-	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/google/uuid v1.3.0 // indirect
-	github.com/klauspost/compress v1.15.0 // indirect
 	github.com/klauspost/cpuid/v2 v2.0.6 // indirect
-	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/segmentio/asm v1.1.0 // indirect
-	github.com/sirupsen/logrus v1.8.1 // indirect
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
 	golang.org/x/net v0.0.0-20220225172249-27dd8689420f // indirect
-	golang.org/x/sys v0.0.0-20220227234510-4e6760a101f9 // indirect
 	golang.org/x/text v0.3.7 // indirect
 	gopkg.in/yaml.v2 v2.3.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -1,7 +1,9 @@
 package kameleoon
 
 import (
-	"fmt"
+	"context"
+	"net/http"
+	"sort"
 	"sync"
 	"time"
 
@@ -55,6 +57,11 @@ func (p GetVariationOptParams) Track(value bool) GetVariationOptParams {
 	return p
 }
 
+// IsTracked returns the value set with Track.
+func (p GetVariationOptParams) IsTracked() bool {
+	return p.track
+}
+
 type GetVariationsOptParams struct {
 	onlyActive bool
 	track      bool
@@ -72,6 +79,16 @@ func (p GetVariationsOptParams) Track(value bool) GetVariationsOptParams {
 	return p
 }
 
+// IsOnlyActive returns the value set with OnlyActive.
+func (p GetVariationsOptParams) IsOnlyActive() bool {
+	return p.onlyActive
+}
+
+// IsTracked returns the value set with Track.
+func (p GetVariationsOptParams) IsTracked() bool {
+	return p.track
+}
+
 type AddDataOptParams struct {
 	track bool
 }
@@ -85,6 +102,11 @@ func (p AddDataOptParams) Track(value bool) AddDataOptParams {
 	return p
 }
 
+// IsTracked returns the value set with Track.
+func (p AddDataOptParams) IsTracked() bool {
+	return p.track
+}
+
 type TrackConversionOptParams struct {
 	Revenue  float64
 	Negative bool
@@ -103,9 +125,45 @@ func (p SetForcedVariationOptParams) ForceTargeting(value bool) SetForcedVariati
 	return p
 }
 
+// IsTargetingForced returns the value set with ForceTargeting.
+func (p SetForcedVariationOptParams) IsTargetingForced() bool {
+	return p.forceTargeting
+}
+
+type SegmentOptParams struct {
+	track bool
+}
+
+func NewSegmentOptParams() SegmentOptParams {
+	return SegmentOptParams{track: false}
+}
+
+// Track sets whether the matching segments are tracked as targeted segments.
+func (p SegmentOptParams) Track(value bool) SegmentOptParams {
+	p.track = value
+	return p
+}
+
+// IsTracked returns the value set with Track.
+func (p SegmentOptParams) IsTracked() bool {
+	return p.track
+}
+
 type KameleoonClient interface {
 	WaitInit() error
 
+	// WaitInitCtx waits for the client initialization the same way as WaitInit does,
+	// but stops waiting as soon as the context is done and returns the context error.
+	WaitInitCtx(ctx context.Context) error
+
+	// Close gracefully shuts the client down: it stops the configuration polling and streaming, tracks
+	// all the pending visitor data and waits for the outstanding tracking requests until the context is done.
+	// The requests still outstanding then are aborted.
+	//
+	// Returns TrackingDataDropped error with the number of the data which were not delivered in time.
+	// The client must not be used after it is closed. Closing an already closed client has no effect.
+	Close(ctx context.Context) error
+
 	// GetVisitorCode should be called to get the Kameleoon visitorCode for the current visitor.
 	//
 	// This is especially important when using Kameleoon in a mixed front-end and back-end environment,
@@ -122,6 +180,15 @@ type KameleoonClient interface {
 
 	SetLegalConsent(visitorCode string, consent bool, response ...*fasthttp.Response) error
 
+	// GetVisitorCodeHTTP is the net/http version of GetVisitorCode.
+	//
+	// The visitor code cookie is read from the request and written to the response writer,
+	// so it must be called before the response header is written.
+	GetVisitorCodeHTTP(request *http.Request, response http.ResponseWriter, defaultVisitorCode ...string) (string, error)
+
+	// SetLegalConsentHTTP is the net/http version of SetLegalConsent.
+	SetLegalConsentHTTP(visitorCode string, consent bool, response ...http.ResponseWriter) error
+
 	// AddData associates various Data with a visitor
 	//
 	// Note that this method doesn't return any value and doesn't interact with the
@@ -146,6 +213,8 @@ type KameleoonClient interface {
 	// This method requires visitorCode and goalID to track conversion on this particular goal.
 	// In addition, this method also accepts revenue as a third optional argument to track revenue.
 	// This method is non-blocking as the server call is made asynchronously.
+	// The tracking and flushing methods have no context-aware versions for this reason, their requests are
+	// bounded by the default timeout and the outstanding ones are awaited by Close.
 	TrackConversion(visitorCode string, goalID int, isUniqueIdentifier ...bool) error
 
 	TrackConversionRevenue(visitorCode string, goalID int, revenue float64, isUniqueIdentifier ...bool) error
@@ -210,6 +279,20 @@ type KameleoonClient interface {
 
 	GetVariations(visitorCode string, params ...GetVariationsOptParams) (map[string]types.Variation, error)
 
+	// GetVariationCtx is the context-aware version of GetVariation.
+	//
+	// returns the context error if the context is done before the evaluation
+	GetVariationCtx(
+		ctx context.Context, visitorCode string, featureKey string, params ...GetVariationOptParams,
+	) (types.Variation, error)
+
+	// GetVariationsCtx is the context-aware version of GetVariations.
+	//
+	// returns the context error if the context is done before all the feature flags are evaluated
+	GetVariationsCtx(
+		ctx context.Context, visitorCode string, params ...GetVariationsOptParams,
+	) (map[string]types.Variation, error)
+
 	// GetFeatureVariationVariables retrieves all feature variable values for a given variation
 	//
 	// This method takes a featureKey and variationKey as mandatory arguments and
@@ -232,6 +315,11 @@ type KameleoonClient interface {
 	// returns Network timeout error
 	GetRemoteData(key string, timeout ...time.Duration) ([]byte, error)
 
+	// GetRemoteDataCtx is the context-aware version of GetRemoteData.
+	// The request is aborted when the context deadline is reached. A cancellation of the context without
+	// a deadline makes the call return at once, but the request may keep running until its timeout.
+	GetRemoteDataCtx(ctx context.Context, key string, timeout ...time.Duration) ([]byte, error)
+
 	// GetVisitorWarehouseAudience retrieves data associated with a visitor's warehouse audiences and adds
 	// it to the visitor. Retrieves all audience data associated with the visitor in your data warehouse using the
 	// specified `visitorCode` and `warehouseKey`. The `warehouseKey` is typically your internal user
@@ -254,6 +342,13 @@ type KameleoonClient interface {
 	// - An error if the visitor code is empty or longer than 255 characters.
 	GetVisitorWarehouseAudience(params VisitorWarehouseAudienceParams) (*types.CustomData, error)
 
+	// GetVisitorWarehouseAudienceCtx is the context-aware version of GetVisitorWarehouseAudience.
+	// The request is aborted when the context deadline is reached. A cancellation of the context without
+	// a deadline makes the call return at once, but the request may keep running until its timeout.
+	GetVisitorWarehouseAudienceCtx(
+		ctx context.Context, params VisitorWarehouseAudienceParams,
+	) (*types.CustomData, error)
+
 	// GetVisitorWarehouseAudienceWithOptParams retrieves data associated with a visitor's warehouse audiences and adds
 	// it to the visitor. Retrieves all audience data associated with the visitor in your data warehouse using the
 	// specified `visitorCode` and `warehouseKey`. The `warehouseKey` is typically your internal user
@@ -277,8 +372,23 @@ type KameleoonClient interface {
 		visitorCode string, customDataIndex int, params ...VisitorWarehouseAudienceOptParams,
 	) (*types.CustomData, error)
 
+	// GetVisitorWarehouseAudienceWithOptParamsCtx is the context-aware version of
+	// GetVisitorWarehouseAudienceWithOptParams.
+	// The request is aborted when the context deadline is reached. A cancellation of the context without
+	// a deadline makes the call return at once, but the request may keep running until its timeout.
+	GetVisitorWarehouseAudienceWithOptParamsCtx(
+		ctx context.Context, visitorCode string, customDataIndex int, params ...VisitorWarehouseAudienceOptParams,
+	) (*types.CustomData, error)
+
 	GetRemoteVisitorData(visitorCode string, addData bool, timeout ...time.Duration) ([]types.Data, error)
 
+	// GetRemoteVisitorDataCtx is the context-aware version of GetRemoteVisitorData.
+	// The request is aborted when the context deadline is reached. A cancellation of the context without
+	// a deadline makes the call return at once, but the request may keep running until its timeout.
+	GetRemoteVisitorDataCtx(
+		ctx context.Context, visitorCode string, addData bool, timeout ...time.Duration,
+	) ([]types.Data, error)
+
 	// Deprecated: Please use `GetRemoteVisitorDataWithFilter`
 	GetRemoteVisitorDataWithOptParams(
 		visitorCode string, addData bool, filter types.RemoteVisitorDataFilter, params ...RemoteVisitorDataOptParams,
@@ -288,8 +398,26 @@ type KameleoonClient interface {
 		visitorCode string, addData bool, filter types.RemoteVisitorDataFilter, params ...RemoteVisitorDataOptParams,
 	) ([]types.Data, error)
 
+	// GetRemoteVisitorDataWithFilterCtx is the context-aware version of GetRemoteVisitorDataWithFilter.
+	// The request is aborted when the context deadline is reached. A cancellation of the context without
+	// a deadline makes the call return at once, but the request may keep running until its timeout.
+	GetRemoteVisitorDataWithFilterCtx(
+		ctx context.Context,
+		visitorCode string,
+		addData bool,
+		filter types.RemoteVisitorDataFilter,
+		params ...RemoteVisitorDataOptParams,
+	) ([]types.Data, error)
+
 	OnUpdateConfiguration(handler func())
 
+	// SubscribeConfigurationChanges registers the handler called every time a new configuration is applied,
+	// with the feature flags changed by it. Any number of handlers can be registered.
+	// The last applied change is replayed to the handler on subscription, so the changes applied
+	// while the client was created (from Bootstrap or the configuration snapshot) are not missed.
+	// The returned function unsubscribes the handler.
+	SubscribeConfigurationChanges(handler func(configuration.ConfigurationChange)) (unsubscribe func())
+
 	// GetFeatureList returns a list of all feature flag keys
 	GetFeatureList() []string
 
@@ -345,6 +473,88 @@ type KameleoonClient interface {
 	// - VisitorCodeInvalid:
 	//   The provided visitor code is invalid.
 	EvaluateAudiences(visitorCode string) error
+
+	// IsVisitorInSegment checks if the visitor matches the segment's conditions.
+	// The result is not tracked unless it is requested with the params.
+	//
+	// May return one of the following errors:
+	// - VisitorCodeInvalid:
+	//   The provided visitor code is invalid.
+	// - SegmentNotFound:
+	//   The segment is not found in the configuration.
+	IsVisitorInSegment(visitorCode string, segmentId int, params ...SegmentOptParams) (bool, error)
+
+	// GetMatchingSegments returns the segments of the configuration which the visitor matches, ordered by id.
+	// The result is not tracked unless it is requested with the params.
+	//
+	// May return one of the following errors:
+	// - VisitorCodeInvalid:
+	//   The provided visitor code is invalid.
+	GetMatchingSegments(visitorCode string, params ...SegmentOptParams) ([]types.SegmentInfo, error)
+
+	// GetVariationStateless calculates the variation of the feature flag for a visitor made of the visitor data
+	// only. Along with the data accepted by AddData, e.g. custom data, device, browser, geolocation and page views,
+	// the visitor state is accepted, e.g. the previously assigned variations (types.AssignedVariation).
+	// The visitors kept by the client are neither read nor written and nothing is tracked: if tracking is enabled,
+	// the assigned variation is returned as sendable data which the caller is responsible for delivering.
+	// Without the legal consent (if it is required) only the targeted delivery variations are returned.
+	// The supplied visitor data, e.g. custom data and device, is not included in the returned sendable data.
+	//
+	// May return one of the following errors:
+	// - VisitorCodeInvalid:
+	//   The provided visitor code is invalid.
+	// - FeatureNotFound:
+	//   The feature flag is not found.
+	// - FeatureEnvironmentDisabled:
+	//   The feature flag is disabled in the environment.
+	GetVariationStateless(
+		visitorCode string, featureKey string, visitorData []types.BaseData, params ...StatelessOptParams,
+	) (types.Variation, []types.Sendable, error)
+
+	// GetVariationsStateless calculates the variations of all the feature flags enabled in the environment
+	// the same way as GetVariationStateless does.
+	//
+	// May return one of the following errors:
+	// - VisitorCodeInvalid:
+	//   The provided visitor code is invalid.
+	GetVariationsStateless(
+		visitorCode string, visitorData []types.BaseData, params ...StatelessOptParams,
+	) (map[string]types.Variation, []types.Sendable, error)
+
+	// EvaluateBatch calculates the variations of the feature flags for many visitors at once.
+	// All the visitors are evaluated in parallel against the same configuration. If no feature keys are
+	// provided, all the feature flags enabled in the environment are evaluated.
+	// By default nothing is tracked and no visitor is created, see EvaluateBatchOptParams.
+	//
+	// The evaluation stops as soon as the context is done, the context error is returned with the partial result.
+	//
+	// May return one of the following errors:
+	// - FeatureNotFound:
+	//   One of the feature flags is not found.
+	// - FeatureEnvironmentDisabled:
+	//   One of the feature flags is disabled in the environment.
+	// Evaluation errors of particular visitors (e.g. VisitorCodeInvalid) are reported in the result.
+	EvaluateBatch(
+		ctx context.Context, visitorCodes []string, featureKeys []string, params ...EvaluateBatchOptParams,
+	) (*types.BatchEvaluationResult, error)
+
+	// ExplainVariation returns the steps which lead to the variation of the feature flag for the visitor.
+	// The variation is evaluated the same way as by GetVariation, but it is neither tracked nor saved.
+	//
+	// May return one of the following errors:
+	// - VisitorCodeInvalid:
+	//   The provided visitor code is invalid.
+	// - FeatureNotFound:
+	//   The feature flag is not found, the explanation is nil.
+	// - FeatureEnvironmentDisabled:
+	//   The feature flag is disabled in the environment or the evaluation is blocked by the consent policy,
+	//   the explanation covers the steps evaluated so far.
+	ExplainVariation(visitorCode string, featureKey string) (*types.VariationExplanation, error)
+
+	// GetVisitorStorageStats returns the number of stored visitors, their approximate memory usage
+	// and the number of visitors evicted because of the visitor storage limits, including the ones
+	// evicted with undelivered data.
+	GetVisitorStorageStats() storage.VisitorStorageStats
 }
 
 type kameleoonClient struct {
@@ -358,6 +568,7 @@ type kameleoonClient struct {
 	remoteDataManager    remotedata.RemoteDataManager
 	trackingManager      tracking.TrackingManager
 	configurationManager configuration.ConfigurationManager
+	evaluator            *variationEvaluator
 
 	m           sync.Mutex
 	readiness   *kameleoonClientReadiness
@@ -389,17 +600,63 @@ func newClient(siteCode string, cfg *KameleoonClientConfig) (*kameleoonClient, e
 
 	df := configuration.NewDataFile(configuration.Configuration{}, "", cfg.Environment)
 	dm := data.NewDataManagerImpl(df)
-	np := network.NewNetProviderImpl(cfg.Network.ReadTimeout, cfg.Network.WriteTimeout,
-		cfg.Network.MaxConnsPerHost, cfg.Network.ProxyURL)
 	up := network.NewUrlProviderImpl(siteCode, cfg.NetworkDomain, utils.SdkName, utils.SdkVersion)
-	atsf := &network.AccessTokenSourceFactoryImpl{ClientId: cfg.ClientID, ClientSecret: cfg.ClientSecret}
-	nm := network.NewNetworkManagerImpl(cfg.Environment, cfg.DefaultTimeout, np, up, atsf)
+	if len(cfg.Network.EndpointOverride) > 0 {
+		up.OverrideEndpoint(cfg.Network.EndpointOverride)
+	}
+	var nm network.NetworkManager
+	trackingCompression := network.CompressionNone
+	if cfg.Offline {
+		nm = network.NewOfflineNetworkManager(cfg.Environment, cfg.DefaultTimeout, up, cfg.OfflineTrackingWriter)
+	} else {
+		np := newNetProvider(&cfg.Network)
+		atsf := &network.AccessTokenSourceFactoryImpl{ClientId: cfg.ClientID, ClientSecret: cfg.ClientSecret}
+		nmi := network.NewNetworkManagerImpl(cfg.Environment, cfg.DefaultTimeout, np, up, atsf)
+		nmi.RetryPolicies = cfg.Network.Retry.WithDefaults()
+		nmi.CircuitBreakers = network.NewCircuitBreakers(cfg.Network.CircuitBreaker)
+		nmi.TrackingCompression = cfg.Network.TrackingCompression
+		trackingCompression = cfg.Network.TrackingCompression
+		nm = nmi
+	}
 	vm := newVisitorManager(dm, cfg)
 	hm, _ := hybrid.NewHybridManagerImpl(5*time.Second, dm)
 	tarM := targeting.NewTargetingManager(dm, vm)
 	rdm := remotedata.NewRemoteDataManager(dm, nm, vm)
-	trM := tracking.NewTrackingManagerImpl(dm, nm, vm, cfg.TrackingInterval)
-	cm := configuration.NewConfigurationManager(dm, nm, &realtime.NetSseClient{}, cfg.RefreshInterval, cfg.Environment)
+	var tq tracking.TrackingQueue
+	if len(cfg.TrackingQueueDir) > 0 {
+		if ftq, err := tracking.NewFileTrackingQueue(cfg.TrackingQueueDir); err == nil {
+			tq = ftq
+		} else {
+			logging.Error("Failed to open the tracking queue, tracking requests are not persisted: %s", err)
+		}
+	}
+	trM := tracking.NewTrackingManagerImplWithCompression(
+		dm, nm, vm, cfg.TrackingInterval, tq, cfg.TrackingSink, cfg.TrackingSinkOnly, trackingCompression,
+	)
+	// Unsent data of the visitors to be evicted goes with the next tracking request rather than being lost
+	vm.SetEvictionHandler(func(visitorCodes []string) {
+		for _, visitorCode := range visitorCodes {
+			trM.AddVisitorCode(visitorCode)
+		}
+	})
+	var ss configuration.SnapshotStore
+	if len(cfg.ConfigurationSnapshotDir) > 0 {
+		ss = configuration.NewFileSnapshotStore(cfg.ConfigurationSnapshotDir, siteCode, cfg.Environment)
+	}
+	if (cfg.Network.NetProvider != nil) && (cfg.Network.HTTPTransport == nil) {
+		logging.Warning("The real-time update stream does not go through NetProvider, " +
+			"set HTTPTransport to route it as well")
+	}
+	sse := realtime.NewHttpSseClient(realtime.SseClientConfig{
+		ProxyURL:         cfg.Network.ProxyURL,
+		TLSConfig:        cfg.Network.tlsConfig,
+		ConnectTimeout:   cfg.Network.ReadTimeout,
+		HeartbeatTimeout: cfg.Network.SseHeartbeatTimeout,
+		Transport:        cfg.Network.HTTPTransport,
+	})
+	cm := configuration.NewConfigurationManager(
+		dm, nm, sse, ss, cfg.RefreshInterval, cfg.Environment, cfg.RealTimeReconnect,
+	)
 	client := newClientInternal(cfg, dm, nm, vm, hm, tarM, rdm, trM, cm)
 	logging.Info("RETURN: newClient(siteCode: %s, config: %s) -> (client, error: <nil>)",
 		siteCode, cfg)
@@ -430,13 +687,64 @@ func newClientInternal(
 		remoteDataManager:    remoteDataManager,
 		trackingManager:      trackingManager,
 		configurationManager: configurationManager,
+		evaluator:            newVariationEvaluatorWithManagers(dataManager, visitorManager, targetingManager),
+	}
+	client.loadLocalConfig()
+	if cfg.Offline {
+		// No fetch is going to happen, so the client is ready with whatever was loaded locally
+		if client.readiness.IsInitializing() {
+			client.readiness.set(nil)
+		}
+	} else {
+		go client.updateConfigInitially()
 	}
-	go client.updateConfigInitially()
 	return client
 }
 
-func newVisitorManager(dm data.DataManager, cfg *KameleoonClientConfig) storage.VisitorManager {
-	return storage.NewVisitorManagerImpl(dm, cfg.SessionDuration)
+// loadLocalConfig makes the client ready before the first fetch if there is a configuration snapshot
+// or a bootstrap configuration.
+func (c *kameleoonClient) loadLocalConfig() {
+	logging.Debug("CALL: kameleoonClient.loadLocalConfig()")
+	var err error
+	loaded := false
+	if len(c.cfg.ConfigurationSnapshotDir) > 0 {
+		loaded = c.configurationManager.RestoreSnapshot() == nil
+	}
+	if !loaded && c.cfg.Bootstrap.isSet() {
+		var rawConfiguration []byte
+		if rawConfiguration, err = c.cfg.Bootstrap.read(); err == nil {
+			err = c.configurationManager.Bootstrap(rawConfiguration)
+		}
+		if err == nil {
+			loaded = true
+		} else {
+			logging.Error("Failed to bootstrap configuration: %s", err)
+		}
+	}
+	if loaded {
+		c.readiness.set(nil)
+	}
+	logging.Debug("RETURN: kameleoonClient.loadLocalConfig()")
+}
+
+func newVisitorManager(dm data.DataManager, cfg *KameleoonClientConfig) *storage.VisitorManagerImpl {
+	limits := storage.VisitorLimits{
+		MaxVisitors: cfg.MaxVisitors, MaxBytes: cfg.MaxVisitorsMemory, Overshoot: cfg.MaxVisitorsOvershoot,
+	}
+	return storage.NewVisitorManagerImplWithStore(dm, cfg.SessionDuration, cfg.VisitorStore, limits)
+}
+
+func newNetProvider(cfg *NetworkConfig) network.NetProvider {
+	switch {
+	case cfg.NetProvider != nil:
+		return cfg.NetProvider
+	case cfg.HTTPTransport != nil:
+		return network.NewHttpNetProviderWithTransport(cfg.HTTPTransport)
+	case cfg.UseNetHTTP:
+		return network.NewHttpNetProvider(cfg.MaxConnsPerHost, cfg.ProxyURL, cfg.tlsConfig)
+	}
+	return network.NewNetProviderImplWithTLS(cfg.ReadTimeout, cfg.WriteTimeout, cfg.MaxConnsPerHost, cfg.ProxyURL,
+		cfg.tlsConfig)
 }
 
 func (c *kameleoonClient) WaitInit() error {
@@ -446,36 +754,120 @@ func (c *kameleoonClient) WaitInit() error {
 	return err
 }
 
+func (c *kameleoonClient) WaitInitCtx(ctx context.Context) error {
+	logging.Info("CALL: kameleoonClient.WaitInitCtx(ctx)")
+	err := c.readiness.WaitCtx(ctx)
+	logging.Info("RETURN: kameleoonClient.WaitInitCtx(ctx) -> (error: %s)", err)
+	return err
+}
+
 func (c *kameleoonClient) close() {
 	logging.Debug("CALL: kameleoonClient.close()")
-	if !c.closed {
-		c.m.Lock()
-		if c.closed {
-			c.m.Unlock()
-		} else {
-			c.closed = true
-			c.m.Unlock()
-			c.visitorManager.Close()
-			c.trackingManager.Close()
-		}
+	if c.markClosed() {
+		c.configurationManager.Close()
+		c.visitorManager.Close()
+		c.trackingManager.Close()
 	}
 	logging.Debug("RETURN: kameleoonClient.close()")
 }
 
+func (c *kameleoonClient) Close(ctx context.Context) (err error) {
+	logging.Info("CALL: kameleoonClient.Close()")
+	defer func() {
+		logging.Info("RETURN: kameleoonClient.Close() -> (error: %s)", err)
+	}()
+	if !c.markClosed() {
+		return nil
+	}
+	// The configuration is stopped aside, so Close never waits for it longer than the context allows
+	configurationClosed := make(chan struct{})
+	go func() {
+		c.configurationManager.Close()
+		close(configurationClosed)
+	}()
+	dropped, drainErr := c.trackingManager.Drain(ctx)
+	c.trackingManager.Close()
+	c.visitorManager.Close()
+	select {
+	case <-configurationClosed:
+	case <-ctx.Done():
+		logging.Warning("Configuration updates were not stopped before the close deadline")
+	}
+	if dropped > 0 {
+		err = errs.NewTrackingDataDropped(dropped, drainErr)
+	}
+	return err
+}
+
+// markClosed returns true if the client was not closed before.
+func (c *kameleoonClient) markClosed() bool {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if c.closed {
+		return false
+	}
+	c.closed = true
+	return true
+}
+
 func (c *kameleoonClient) GetVisitorCode(request *fasthttp.Request, response *fasthttp.Response,
 	defaultVisitorCode ...string) (string, error) {
 	logging.Info("CALL: kameleoonClient.GetVisitorCode(request, response, defaultVisitorCode: %s)",
 		defaultVisitorCode)
-	visitorCode, err := c.cookieManager.GetOrAdd(request, response, defaultVisitorCode...)
+	visitorCode, err := c.cookieManager.GetOrAdd(
+		cookie.NewFastHttpRequestCookies(request), cookie.NewFastHttpResponseCookies(response), defaultVisitorCode...,
+	)
 	logging.Info(
 		"RETURN: kameleoonClient.GetVisitorCode(request, response, defaultVisitorCode: %s) -> "+
 			"(visitorCode: %s, error: %s)", defaultVisitorCode, visitorCode, err)
 	return visitorCode, err
 }
 
+func (c *kameleoonClient) GetVisitorCodeHTTP(request *http.Request, response http.ResponseWriter,
+	defaultVisitorCode ...string) (string, error) {
+	logging.Info("CALL: kameleoonClient.GetVisitorCodeHTTP(request, response, defaultVisitorCode: %s)",
+		defaultVisitorCode)
+	visitorCode, err := c.cookieManager.GetOrAdd(
+		cookie.NewHttpRequestCookies(request), cookie.NewHttpResponseCookies(response), defaultVisitorCode...,
+	)
+	logging.Info(
+		"RETURN: kameleoonClient.GetVisitorCodeHTTP(request, response, defaultVisitorCode: %s) -> "+
+			"(visitorCode: %s, error: %s)", defaultVisitorCode, visitorCode, err)
+	return visitorCode, err
+}
+
 func (c *kameleoonClient) SetLegalConsent(visitorCode string, consent bool, response ...*fasthttp.Response) error {
 	logging.Info("CALL: kameleoonClient.SetLegalConsent(visitorCode: %s, consent: %s, response)",
 		visitorCode, consent)
+	var responseCookies cookie.ResponseCookies
+	if len(response) > 0 {
+		responseCookies = cookie.NewFastHttpResponseCookies(response[0])
+	}
+	err := c.setLegalConsent(visitorCode, consent, responseCookies)
+	logging.Info("RETURN: kameleoonClient.SetLegalConsent(visitorCode: %s, consent: %s, response) -> (error: %s)",
+		visitorCode, consent, err)
+	return err
+}
+
+func (c *kameleoonClient) SetLegalConsentHTTP(
+	visitorCode string, consent bool, response ...http.ResponseWriter,
+) error {
+	logging.Info("CALL: kameleoonClient.SetLegalConsentHTTP(visitorCode: %s, consent: %s, response)",
+		visitorCode, consent)
+	var responseCookies cookie.ResponseCookies
+	if len(response) > 0 {
+		responseCookies = cookie.NewHttpResponseCookies(response[0])
+	}
+	err := c.setLegalConsent(visitorCode, consent, responseCookies)
+	logging.Info(
+		"RETURN: kameleoonClient.SetLegalConsentHTTP(visitorCode: %s, consent: %s, response) -> (error: %s)",
+		visitorCode, consent, err)
+	return err
+}
+
+func (c *kameleoonClient) setLegalConsent(
+	visitorCode string, consent bool, response cookie.ResponseCookies,
+) error {
 	err := utils.ValidateVisitorCode(visitorCode)
 	if err == nil {
 		v := c.visitorManager.GetOrCreateVisitor(visitorCode)
@@ -484,12 +876,11 @@ func (c *kameleoonClient) SetLegalConsent(visitorCode string, consent bool, resp
 		} else {
 			v.SetLegalConsent(types.LegalConsentNotGiven)
 		}
-		if len(response) > 0 {
-			c.cookieManager.Update(visitorCode, consent, response[0])
+		c.visitorManager.Persist(visitorCode, v)
+		if response != nil {
+			c.cookieManager.Update(visitorCode, consent, response)
 		}
 	}
-	logging.Info("RETURN: kameleoonClient.SetLegalConsent(visitorCode: %s, consent: %s, response) -> (error: %s)",
-		visitorCode, consent, err)
 	return err
 }
 
@@ -657,227 +1048,38 @@ func (c *kameleoonClient) getFeatureVariationKey(
 	}
 	visitor := c.visitorManager.GetVisitor(visitorCode)
 	var evalExp *evaluatedExperiment
-	if evalExp, err = c.evaluate(visitor, visitorCode, featureFlag, true, true); err != nil {
+	if evalExp, err = c.evaluator.evaluate(visitor, visitorCode, featureFlag, true, true, nil); err != nil {
 		return
 	}
 	// get variation key from feature flag
 	defaultVariationKey := featureFlag.GetDefaultVariationKey()
-	variationKey = c.calculateVariationKey(evalExp, defaultVariationKey)
+	variationKey = c.evaluator.calculateVariationKey(evalExp, defaultVariationKey)
 	c.trackingManager.AddVisitorCode(visitorCode)
 	return
 }
 
-func (c *kameleoonClient) saveVariation(
-	visitorCode string, evalExp *evaluatedExperiment, track bool,
-) {
-	if (evalExp == nil) || (evalExp.experiment.ExperimentId == 0) || (evalExp.varByExp.VariationID == nil) {
-		return
-	}
-	logging.Debug(
-		"CALL: kameleoonClient.saveVariation(visitorCode: %s, evalExp: %s, track: %s)",
-		visitorCode, evalExp, track,
-	)
-	visitor := c.visitorManager.GetOrCreateVisitor(visitorCode)
-	asVariation := types.NewAssignedVariation(
-		evalExp.experiment.ExperimentId, *evalExp.varByExp.VariationID, evalExp.ruleType,
-	)
-	if !track {
-		asVariation.MarkAsSent()
-	}
-	visitor.AssignVariation(asVariation)
-	logging.Debug(
-		"RETURN: kameleoonClient.saveVariation(visitorCode: %s, evalExp: %s, track: %s)",
-		visitorCode, evalExp, track,
-	)
-}
-
-func (c *kameleoonClient) calculateVariationKey(evalExp *evaluatedExperiment, defaultVariationKey string) string {
-	logging.Debug(
-		"CALL: kameleoonClient.calculateVariationKey(evalExp: %s, defaultVariationKey: %s)",
-		evalExp, defaultVariationKey,
-	)
-	var variationKey string
-	if evalExp != nil {
-		variationKey = evalExp.varByExp.VariationKey
-	} else {
-		variationKey = defaultVariationKey
-	}
-	logging.Debug(
-		"RETURN: kameleoonClient.calculateVariationKey(evalExp: %s, defaultVariationKey: %s) -> "+
-			"(variationKey: %s)", evalExp, defaultVariationKey, variationKey,
-	)
-	return variationKey
+func getCodeForHash(visitor storage.Visitor, visitorCode string, bucketingCustomDataIndex *int) string {
+	codeForHash, _ := getCodeForHashWithSource(visitor, visitorCode, bucketingCustomDataIndex)
+	return codeForHash
 }
 
-func getCodeForHash(visitor storage.Visitor, visitorCode string, bucketingCustomDataIndex *int) string {
+func getCodeForHashWithSource(
+	visitor storage.Visitor, visitorCode string, bucketingCustomDataIndex *int,
+) (string, types.BucketingKeySource) {
 	if visitor != nil {
 		// 1. Try to use the bucketing custom data's value if bucketingCustomDataIndex is defined
 		if bucketingCustomDataIndex != nil {
 			bucketingCustomData := visitor.CustomData().Get(*bucketingCustomDataIndex)
 			if (bucketingCustomData != nil) && (len(bucketingCustomData.Values()) > 0) {
-				return bucketingCustomData.Values()[0]
+				return bucketingCustomData.Values()[0], types.BucketingKeySourceCustomData
 			}
 		}
 		// 2. Use mappingIdentifier instead of visitorCode if it was set up
 		if visitor.MappingIdentifier() != nil {
-			return *visitor.MappingIdentifier()
-		}
-	}
-	return visitorCode
-}
-
-func (c *kameleoonClient) evaluateCBScores(
-	visitor storage.Visitor, visitorCode string, rule types.IRule, bucketingCustomDataIndex *int,
-) *evaluatedExperiment {
-	if (visitor == nil) || (visitor.CBScores() == nil) {
-		return nil
-	}
-	logging.Debug(
-		"CALL: kameleoonClient.evaluateCBScores(visitor, visitorCode: %s, rule: %s, bucketingCustomDataIndex: %s)",
-		visitorCode, rule, bucketingCustomDataIndex,
-	)
-	var evalExp *evaluatedExperiment
-	ruleVarsByExp := rule.GetRuleBase().VariationsByExposition
-	if varIdGroupByScores, ok := visitor.CBScores().Values()[rule.GetRuleBase().ExperimentId]; ok {
-		var varByExpInCbs []*types.VariationByExposition
-		for _, varGroup := range varIdGroupByScores {
-			// Finding varByExps which exist in CBS variation IDs
-			varByExpInCbs = make([]*types.VariationByExposition, 0, len(ruleVarsByExp))
-			for i := 0; i < len(ruleVarsByExp); i++ {
-				if (ruleVarsByExp[i].VariationID != nil) &&
-					utils.SliceContains(varGroup.Ids(), *ruleVarsByExp[i].VariationID) {
-					varByExpInCbs = append(varByExpInCbs, &ruleVarsByExp[i])
-				}
-			}
-			if len(varByExpInCbs) > 0 { // Skiping if not found any varByExp
-				break // We need take only one list with the highest scores
-			}
-		}
-		if len(varByExpInCbs) > 0 {
-			var idx int
-			if len(varByExpInCbs) > 1 { // if more than one varByExp for score -> randomly get
-				codeForHash := getCodeForHash(visitor, visitorCode, bucketingCustomDataIndex)
-				variationHash := utils.ObtainHashRule(
-					codeForHash, rule.GetRuleBase().ExperimentId, rule.GetRuleBase().RespoolTime,
-				)
-				logging.Debug("Calculated CBS hash %s for code %s", variationHash, codeForHash)
-				idx = int(variationHash * float64(len(varByExpInCbs)))
-				if idx >= len(varByExpInCbs) {
-					idx = len(varByExpInCbs) - 1
-				}
-			}
-			evalExp = newEvaluatedExperimentFromVarByExpRule(varByExpInCbs[idx], rule)
-		}
-	}
-	logging.Debug(
-		"RETURN: kameleoonClient.evaluateCBScores(visitor, visitorCode: %s, rule: %s, bucketingCustomDataIndex: %s)"+
-			" -> (evalExp: %s)", visitorCode, rule, bucketingCustomDataIndex, evalExp,
-	)
-	return evalExp
-}
-
-// getVariationRuleForFeature is a helper method for calculate variation key for feature flag
-func (c *kameleoonClient) calculateVariationRuleForFeature(
-	visitorCode string, featureFlag types.IFeatureFlag,
-) (evalExp *evaluatedExperiment, err error) {
-	logging.Debug(
-		"CALL: kameleoonClient.calculateVariationRuleForFeature(visitorCode: %s, featureFlag: %s)",
-		visitorCode, featureFlag,
-	)
-	defer func() {
-		logging.Debug(
-			"RETURN: kameleoonClient.calculateVariationRuleForFeature(visitorCode: %s, featureFlag: %s)"+
-				" -> (evalExp: %s, err: %s)",
-			visitorCode, featureFlag, evalExp, err,
-		)
-	}()
-	visitor := c.visitorManager.GetVisitor(visitorCode)
-	consent, blockingBehaviour := c.getConsentAndBlockingBehaviour(visitor)
-	codeForHash := getCodeForHash(visitor, visitorCode, featureFlag.GetBucketingCustomDataIndex())
-	// no rules -> return DefaultVariationKey
-	for _, rule := range featureFlag.GetRules() {
-		var forcedVariation *types.ForcedExperimentVariation
-		if visitor != nil {
-			forcedVariation = visitor.GetForcedExperimentVariation(rule.GetRuleBase().ExperimentId)
-			if (forcedVariation != nil) && forcedVariation.ForceTargeting() {
-				// Forcing experiment variation in force-targeting mode
-				return newEvaluatedExperimentFromVarByExpRule(forcedVariation.VarByExp(), rule), nil
-			}
-		}
-		// check if visitor is targeted for rule, else next rule
-		if !c.targetingManager.CheckTargeting(visitorCode, rule.GetRuleBase().ExperimentId, rule.GetTargetingSegment()) {
-			continue
-		}
-		if forcedVariation != nil {
-			// Forcing experiment variation in targeting-only mode
-			return newEvaluatedExperimentFromVarByExpRule(forcedVariation.VarByExp(), rule), nil
-		}
-
-		// Disable searching in variation storage (uncommented if you need use variation storage)
-		// check for saved variation for rule if it's experimentation rule
-		// if savedVariation, found := c.getSavedVariationForRule(visitorCode, &rule); found {
-		// 	return savedVariation, &rule, false
-		// }
-
-		// used for rule exposition
-		hashRule := utils.ObtainHashRule(codeForHash, rule.GetRuleBase().Id, rule.GetRuleBase().RespoolTime)
-		logging.Debug("Calculated rule hash %s for code %s", hashRule, codeForHash)
-		// check main expostion for rule with hashRule
-		if hashRule <= rule.GetRuleBase().Exposition {
-			// Checking if the evaluation is blocked due to the consent policy
-			if (consent == types.LegalConsentNotGiven) && (rule.GetRuleBase().Type == types.RuleTypeExperimentation) {
-				if blockingBehaviour == types.PartiallyBlockedByConsent {
-					return nil, nil
-				}
-				// TODO: In the next major, create a new error type for the Completely Blocked by Consent error
-				return nil, errs.NewFeatureEnvironmentDisabledWithMessage(fmt.Sprintf(
-					"Evaluation of %v is blocked because consent is not provided for visitor '%s'",
-					rule, visitorCode,
-				))
-			}
-			// check main exposition for rule with hashRule
-			evalExp = c.evaluateCBScores(visitor, visitorCode, rule, featureFlag.GetBucketingCustomDataIndex())
-			if evalExp != nil {
-				return
-			}
-			if rule.IsTargetDeliveryType() {
-				var variation *types.VariationByExposition
-				if len(rule.GetRuleBase().VariationsByExposition) > 0 {
-					variation = &rule.GetRuleBase().VariationsByExposition[0]
-				}
-				return newEvaluatedExperimentFromVarByExpRule(variation, rule), nil
-			}
-			// used for variation's expositions
-			hashVariation := utils.ObtainHashRule(
-				codeForHash, rule.GetRuleBase().ExperimentId, rule.GetRuleBase().RespoolTime,
-			)
-			logging.Debug("Calculated variation hash %s for code %s", hashVariation, codeForHash)
-			// get variation with new hashVariation
-			variation := rule.GetVariationByHash(hashVariation)
-			if variation != nil {
-				return newEvaluatedExperimentFromVarByExpRule(variation, rule), nil
-			}
-		}
-		if rule.IsTargetDeliveryType() {
-			break
+			return *visitor.MappingIdentifier(), types.BucketingKeySourceMappingIdentifier
 		}
 	}
-	return nil, nil
-}
-
-func (c *kameleoonClient) getConsentAndBlockingBehaviour(visitor storage.Visitor) (types.LegalConsent, types.ConsentBlockingBehaviour) {
-	dataFile := c.dataManager.DataFile()
-
-	consent := types.LegalConsentGiven
-	if dataFile.Settings().IsConsentRequired() {
-		consent = types.LegalConsentUnknown
-		if visitor != nil {
-			consent = visitor.LegalConsent()
-		}
-	}
-	behaviour := dataFile.Settings().BlockingBehaviourIfConsentNotGiven()
-
-	return consent, behaviour
+	return visitorCode, types.BucketingKeySourceVisitorCode
 }
 
 // func (c *kameleoonClient) getSavedVariationForRule(visitorCode string, rule *configuration.Rule) (*types.VariationByExposition, bool) {
@@ -995,7 +1197,7 @@ func (c *kameleoonClient) isFeatureActive(
 		return false, err
 	}
 	var variationKey string
-	variationKey, _, err = c.getVariationInfo(visitorCode, featureFlag, track)
+	variationKey, _, err = c.evaluator.getVariationInfo(visitorCode, featureFlag, track)
 	if ok, err := ignoreFeatureEnvDisabled(err); !ok {
 		return false, err
 	}
@@ -1019,12 +1221,37 @@ func (c *kameleoonClient) GetVariation(
 				"(variation: %s, err: %s)", visitorCode, featureKey, params, externalVariation, err,
 		)
 	}()
+	return c.getVariation(context.Background(), visitorCode, featureKey, params...)
+}
+
+func (c *kameleoonClient) GetVariationCtx(
+	ctx context.Context, visitorCode string, featureKey string, params ...GetVariationOptParams,
+) (externalVariation types.Variation, err error) {
+	logging.Info(
+		"CALL: kameleoonClient.GetVariationCtx(ctx, visitorCode: %s, featureKey: %s, params: %s)",
+		visitorCode, featureKey, params,
+	)
+	defer func() {
+		logging.Info(
+			"RETURN: kameleoonClient.GetVariationCtx(ctx, visitorCode: %s, featureKey: %s, params: %s) -> "+
+				"(variation: %s, err: %s)", visitorCode, featureKey, params, externalVariation, err,
+		)
+	}()
+	return c.getVariation(ctx, visitorCode, featureKey, params...)
+}
+
+func (c *kameleoonClient) getVariation(
+	ctx context.Context, visitorCode string, featureKey string, params ...GetVariationOptParams,
+) (externalVariation types.Variation, err error) {
 	var p GetVariationOptParams
 	if len(params) > 0 {
 		p = params[0]
 	} else {
 		p = NewGetVariationOptParams()
 	}
+	if err = ctx.Err(); err != nil {
+		return
+	}
 	if err = utils.ValidateVisitorCode(visitorCode); err != nil {
 		return
 	}
@@ -1034,7 +1261,7 @@ func (c *kameleoonClient) GetVariation(
 	}
 	var variationKey string
 	var evalExp *evaluatedExperiment
-	if variationKey, evalExp, err = c.getVariationInfo(visitorCode, featureFlag, p.track); err != nil {
+	if variationKey, evalExp, err = c.evaluator.getVariationInfo(visitorCode, featureFlag, p.track); err != nil {
 		return
 	}
 	variation, _ := featureFlag.GetVariationByKey(variationKey)
@@ -1058,6 +1285,28 @@ func (c *kameleoonClient) GetVariations(
 			visitorCode, params, variations, err,
 		)
 	}()
+	return c.getVariations(context.Background(), visitorCode, params...)
+}
+
+func (c *kameleoonClient) GetVariationsCtx(
+	ctx context.Context, visitorCode string, params ...GetVariationsOptParams,
+) (variations map[string]types.Variation, err error) {
+	logging.Info(
+		"CALL: kameleoonClient.GetVariationsCtx(ctx, visitorCode: %s, params: %s)",
+		visitorCode, params,
+	)
+	defer func() {
+		logging.Info(
+			"RETURN: kameleoonClient.GetVariationsCtx(ctx, visitorCode: %s, params: %s) -> "+
+				"(variations: %s, err: %s)", visitorCode, params, variations, err,
+		)
+	}()
+	return c.getVariations(ctx, visitorCode, params...)
+}
+
+func (c *kameleoonClient) getVariations(
+	ctx context.Context, visitorCode string, params ...GetVariationsOptParams,
+) (variations map[string]types.Variation, err error) {
 	var p GetVariationsOptParams
 	if len(params) > 0 {
 		p = params[0]
@@ -1069,12 +1318,15 @@ func (c *kameleoonClient) GetVariations(
 	}
 	variations = make(map[string]types.Variation)
 	for _, ff := range c.dataManager.DataFile().GetOrderedFeatureFlags() {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
 		if !ff.GetEnvironmentEnabled() {
 			continue
 		}
 		var variationKey string
 		var evalExp *evaluatedExperiment
-		variationKey, evalExp, err = c.getVariationInfo(visitorCode, ff, p.track)
+		variationKey, evalExp, err = c.evaluator.getVariationInfo(visitorCode, ff, p.track)
 		if err == nil {
 			if p.onlyActive && (variationKey == string(types.VariationOff)) {
 				continue
@@ -1095,130 +1347,6 @@ func (c *kameleoonClient) GetVariations(
 	return
 }
 
-func (c *kameleoonClient) getVariationInfo(
-	visitorCode string, featureFlag types.IFeatureFlag, track bool,
-) (variationKey string, evalExp *evaluatedExperiment, err error) {
-	logging.Debug(
-		"CALL: kameleoonClient.getVariationInfo(visitorCode: %s, featureFlag: %s, track: %s)",
-		visitorCode, featureFlag, track,
-	)
-	visitor := c.visitorManager.GetVisitor(visitorCode)
-	evalExp, err = c.evaluate(visitor, visitorCode, featureFlag, track, true)
-	if err == nil {
-		defaultVariationKey := featureFlag.GetDefaultVariationKey()
-		variationKey = c.calculateVariationKey(evalExp, defaultVariationKey)
-	}
-	logging.Debug(
-		"RETURN: kameleoonClient.getVariationInfo(visitorCode: %s, featureFlag: %s, track: %s)"+
-			" -> (variationKey: %s, evalExp: %s, err: %s)", visitorCode, featureFlag, track, variationKey, evalExp, err,
-	)
-	return
-}
-
-func (c *kameleoonClient) evaluate(
-	visitor storage.Visitor, visitorCode string, featureFlag types.IFeatureFlag, track, save bool,
-) (evalExp *evaluatedExperiment, err error) {
-	logging.Debug(
-		"CALL: kameleoonClient.evaluate(visitor, visitorCode: %s, featureFlag: %s, track: %s, save: %s)",
-		visitorCode, featureFlag, track, save,
-	)
-	defer func() {
-		logging.Debug(
-			"RETURN: kameleoonClient.evaluate(visitor, visitorCode: %s, featureFlag: %s, track: %s, save: %s)"+
-				" -> (evalExp: %s, err: %s)", visitorCode, featureFlag, track, save, evalExp, err,
-		)
-	}()
-	var forcedVariation *types.ForcedFeatureVariation
-	if visitor != nil {
-		forcedVariation = visitor.GetForcedFeatureVariation(featureFlag.GetFeatureKey())
-	}
-	if forcedVariation != nil {
-		evalExp = newEvaluatedExperimentFromForcedVariation(forcedVariation)
-	} else {
-		var isVisitorNotInHoldout bool
-		isVisitorNotInHoldout, err = c.isVisitorNotInHoldout(
-			visitor, visitorCode, track, save, featureFlag.GetBucketingCustomDataIndex(),
-		)
-		if err != nil {
-			return
-		}
-		if isVisitorNotInHoldout && c.isFFUnrestrictedByMEGroup(visitor, visitorCode, featureFlag) {
-			if evalExp, err = c.calculateVariationRuleForFeature(visitorCode, featureFlag); err != nil {
-				return
-			}
-		}
-	}
-	if save && ((forcedVariation == nil) || !forcedVariation.Simulated()) {
-		c.saveVariation(visitorCode, evalExp, track)
-	}
-	return
-}
-
-func (c *kameleoonClient) isFFUnrestrictedByMEGroup(
-	visitor storage.Visitor, visitorCode string, featureFlag types.IFeatureFlag,
-) bool {
-	meGroupName := featureFlag.GetMEGroupName()
-	if meGroupName == "" {
-		return true
-	}
-	logging.Debug(
-		"CALL: kameleoonClient.isFFUnrestrictedByMEGroup(visitor, visitorCode: %s, featureFlag: %s)",
-		visitorCode, featureFlag,
-	)
-	unrestricted := true
-	if meGroup := c.dataManager.DataFile().MEGroups()[meGroupName]; meGroup != nil {
-		codeForHash := getCodeForHash(visitor, visitorCode, featureFlag.GetBucketingCustomDataIndex())
-		meGroupHash := utils.ObtainHashForMEGroup(codeForHash, meGroupName)
-		logging.Debug("Calculated ME group hash %s for code: %s, meGroup: %s", meGroupHash, codeForHash, meGroupName)
-		unrestricted = meGroup.GetFeatureFlagByHash(meGroupHash) == featureFlag
-	}
-	logging.Debug(
-		"RETURN: kameleoonClient.isFFUnrestrictedByMEGroup(visitor, visitorCode: %s, featureFlag: %s)"+
-			" -> (unrestricted: %s)", visitorCode, featureFlag, unrestricted,
-	)
-	return unrestricted
-}
-
-func (c *kameleoonClient) isVisitorNotInHoldout(
-	visitor storage.Visitor, visitorCode string, track, save bool, bucketingCustomDataIndex *int,
-) (isNotInHoldout bool, err error) {
-	holdout := c.dataManager.DataFile().Holdout()
-	isNotInHoldout = true
-	if holdout == nil {
-		return
-	}
-	logging.Debug(
-		"CALL: kameleoonClient.isVisitorNotInHoldout(visitor, visitorCode: %s, track: %s, save: %s,"+
-			" bucketingCustomDataIndex: %s)", visitorCode, track, save, bucketingCustomDataIndex,
-	)
-	consent, blockingBehaviour := c.getConsentAndBlockingBehaviour(visitor)
-	if consent == types.LegalConsentNotGiven && blockingBehaviour == types.CompletelyBlockedByConsent {
-		err = errs.NewFeatureEnvironmentDisabledWithMessage("Evaluation for a holdout is blocked because visitor's consent was not provided.")
-		return
-	}
-	const inHoldoutVariationKey = "in-holdout"
-	codeForHash := getCodeForHash(visitor, visitorCode, bucketingCustomDataIndex)
-	variationHash := utils.ObtainHash(codeForHash, holdout.ExperimentId)
-	logging.Debug("Calculated holdout hash %s for code %s", variationHash, codeForHash)
-	if varByExp := holdout.GetVariationByHash(variationHash); varByExp != nil {
-		isNotInHoldout = varByExp.VariationKey != inHoldoutVariationKey
-		if save {
-			evalExp := &evaluatedExperiment{
-				varByExp:   varByExp,
-				experiment: holdout,
-				ruleType:   types.RuleTypeExperimentation,
-			}
-			c.saveVariation(visitorCode, evalExp, track)
-		}
-	}
-	logging.Debug(
-		"RETURN: kameleoonClient.isVisitorNotInHoldout(visitor, visitorCode: %s, track: %s, save: %s,"+
-			" bucketingCustomDataIndex: %s) -> (isNotInHoldout: %s)",
-		visitorCode, track, save, bucketingCustomDataIndex, isNotInHoldout,
-	)
-	return
-}
-
 func createExternalVariation(
 	internalVariation *types.VariationFeatureFlag, evalExp *evaluatedExperiment,
 ) (variation types.Variation) {
@@ -1302,12 +1430,21 @@ func parseFeatureVariable(variable *types.Variable) interface{} {
 
 func (c *kameleoonClient) GetRemoteData(key string, timeout ...time.Duration) ([]byte, error) {
 	logging.Info("CALL: kameleoonClient.GetRemoteData(key: %s, timeout: %s)", key, timeout)
-	remoteData, err := c.remoteDataManager.GetData(key, timeout...)
+	remoteData, err := c.remoteDataManager.GetData(context.Background(), key, timeout...)
 	logging.Info("RETURN: kameleoonClient.GetRemoteData(key: %s, timeout: %s) -> (remoteData: %s, error: %s)",
 		remoteData, err)
 	return remoteData, err
 }
 
+func (c *kameleoonClient) GetRemoteDataCtx(ctx context.Context, key string, timeout ...time.Duration) ([]byte, error) {
+	logging.Info("CALL: kameleoonClient.GetRemoteDataCtx(ctx, key: %s, timeout: %s)", key, timeout)
+	remoteData, err := c.remoteDataManager.GetData(ctx, key, timeout...)
+	logging.Info(
+		"RETURN: kameleoonClient.GetRemoteDataCtx(ctx, key: %s, timeout: %s) -> (remoteData: %s, error: %s)",
+		key, timeout, remoteData, err)
+	return remoteData, err
+}
+
 func (c *kameleoonClient) GetRemoteVisitorData(
 	visitorCode string,
 	addData bool,
@@ -1316,13 +1453,31 @@ func (c *kameleoonClient) GetRemoteVisitorData(
 	logging.Info("CALL: kameleoonClient.GetRemoteVisitorData(visitorCode: %s, addData: %s, timeout: %s)",
 		visitorCode, addData, timeout)
 	filter := types.DefaultRemoteVisitorDataFilter()
-	visitorData, err := c.remoteDataManager.GetVisitorData(visitorCode, filter, addData, timeout...)
+	visitorData, err := c.remoteDataManager.GetVisitorData(
+		context.Background(), visitorCode, filter, addData, timeout...,
+	)
 	logging.Info(
 		"RETURN: kameleoonClient.GetRemoteVisitorData(visitorCode: %s, addData: %s, timeout: %s) -> "+
 			"(visitorData: %s, error: %s)", visitorCode, addData, timeout, visitorData, err)
 	return visitorData, err
 }
 
+func (c *kameleoonClient) GetRemoteVisitorDataCtx(
+	ctx context.Context,
+	visitorCode string,
+	addData bool,
+	timeout ...time.Duration,
+) ([]types.Data, error) {
+	logging.Info("CALL: kameleoonClient.GetRemoteVisitorDataCtx(ctx, visitorCode: %s, addData: %s, timeout: %s)",
+		visitorCode, addData, timeout)
+	filter := types.DefaultRemoteVisitorDataFilter()
+	visitorData, err := c.remoteDataManager.GetVisitorData(ctx, visitorCode, filter, addData, timeout...)
+	logging.Info(
+		"RETURN: kameleoonClient.GetRemoteVisitorDataCtx(ctx, visitorCode: %s, addData: %s, timeout: %s) -> "+
+			"(visitorData: %s, error: %s)", visitorCode, addData, timeout, visitorData, err)
+	return visitorData, err
+}
+
 func (c *kameleoonClient) GetRemoteVisitorDataWithOptParams(
 	visitorCode string, addData bool, filter types.RemoteVisitorDataFilter, params ...RemoteVisitorDataOptParams,
 ) ([]types.Data, error) {
@@ -1338,7 +1493,9 @@ func (c *kameleoonClient) GetRemoteVisitorDataWithOptParams(
 		timeout = []time.Duration{p.Timeout}
 	}
 	c.setUniqueIdentifier(visitorCode, p.IsUniqueIdentifier)
-	visitorData, err := c.remoteDataManager.GetVisitorData(visitorCode, filter, addData, timeout...)
+	visitorData, err := c.remoteDataManager.GetVisitorData(
+		context.Background(), visitorCode, filter, addData, timeout...,
+	)
 	logging.Info(
 		"RETURN: kameleoonClient.GetRemoteVisitorDataWithOptParams(visitorCode: %s, addData: %s, filter: %s, "+
 			"params: %s) -> (visitorData: %s, error: %s)", visitorCode, addData, filter, params, visitorData, err)
@@ -1351,6 +1508,41 @@ func (c *kameleoonClient) GetRemoteVisitorDataWithFilter(
 	logging.Info(
 		"CALL: kameleoonClient.GetRemoteVisitorDataWithFilter(visitorCode: %s, addData: %s, filter: %s, params: %s)",
 		visitorCode, addData, filter, params)
+	remoteVisitorData, err := c.getRemoteVisitorDataWithFilter(
+		context.Background(), visitorCode, addData, filter, params...,
+	)
+	logging.Info(
+		"RETURN: kameleoonClient.GetRemoteVisitorDataWithFilter(visitorCode: %s, addData: %s, filter: %s,"+
+			" params: %s) -> (remoteVisitorData: %s, error: %s)",
+		visitorCode, addData, filter, params, remoteVisitorData, err)
+	return remoteVisitorData, err
+}
+
+func (c *kameleoonClient) GetRemoteVisitorDataWithFilterCtx(
+	ctx context.Context,
+	visitorCode string,
+	addData bool,
+	filter types.RemoteVisitorDataFilter,
+	params ...RemoteVisitorDataOptParams,
+) ([]types.Data, error) {
+	logging.Info(
+		"CALL: kameleoonClient.GetRemoteVisitorDataWithFilterCtx(ctx, visitorCode: %s, addData: %s, filter: %s, "+
+			"params: %s)", visitorCode, addData, filter, params)
+	remoteVisitorData, err := c.getRemoteVisitorDataWithFilter(ctx, visitorCode, addData, filter, params...)
+	logging.Info(
+		"RETURN: kameleoonClient.GetRemoteVisitorDataWithFilterCtx(ctx, visitorCode: %s, addData: %s, filter: %s,"+
+			" params: %s) -> (remoteVisitorData: %s, error: %s)",
+		visitorCode, addData, filter, params, remoteVisitorData, err)
+	return remoteVisitorData, err
+}
+
+func (c *kameleoonClient) getRemoteVisitorDataWithFilter(
+	ctx context.Context,
+	visitorCode string,
+	addData bool,
+	filter types.RemoteVisitorDataFilter,
+	params ...RemoteVisitorDataOptParams,
+) ([]types.Data, error) {
 	var p RemoteVisitorDataOptParams
 	if len(params) > 0 {
 		p = params[0]
@@ -1359,18 +1551,17 @@ func (c *kameleoonClient) GetRemoteVisitorDataWithFilter(
 	if p.Timeout > 0 {
 		timeout = []time.Duration{p.Timeout}
 	}
-	remoteVisitorData, err := c.remoteDataManager.GetVisitorData(visitorCode, filter, addData, timeout...)
-	logging.Info(
-		"RETURN: kameleoonClient.GetRemoteVisitorDataWithFilter(visitorCode: %s, addData: %s, filter: %s,"+
-			" params: %s) -> (remoteVisitorData: %s, error: %s)",
-		visitorCode, addData, filter, params, remoteVisitorData, err)
-	return remoteVisitorData, err
+	return c.remoteDataManager.GetVisitorData(ctx, visitorCode, filter, addData, timeout...)
 }
 
 func (c *kameleoonClient) updateConfigInitially() {
 	logging.Debug("CALL: kameleoonClient.updateConfigInitially()")
 	err := c.configurationManager.Start()
-	c.readiness.set(err)
+	if c.readiness.IsInitializing() {
+		c.readiness.set(err)
+	} else if err != nil {
+		logging.Warning("Initial configuration fetch failed, the locally loaded configuration is used: %s", err)
+	}
 	logging.Debug("RETURN: kameleoonClient.updateConfigInitially()")
 }
 
@@ -1379,6 +1570,12 @@ func (c *kameleoonClient) OnUpdateConfiguration(handler func()) {
 	logging.Info("CALL/RETURN: kameleoonClient.OnUpdateConfiguration(handler)")
 }
 
+func (c *kameleoonClient) SubscribeConfigurationChanges(handler func(configuration.ConfigurationChange)) func() {
+	unsubscribe := c.configurationManager.SubscribeChanges(handler)
+	logging.Info("CALL/RETURN: kameleoonClient.SubscribeConfigurationChanges(handler)")
+	return unsubscribe
+}
+
 /*
 func (c *kameleoonClient) getValidSavedVariation(visitorCode string, experiment *configuration.Experiment) (int, bool) {
 	//get saved variation
@@ -1426,9 +1623,9 @@ func (c *kameleoonClient) GetActiveFeatureListForVisitor(visitorCode string) (ar
 				continue
 			}
 			var evalExp *evaluatedExperiment
-			evalExp, err = c.evaluate(visitor, visitorCode, ff, false, false)
+			evalExp, err = c.evaluator.evaluate(visitor, visitorCode, ff, false, false, nil)
 			if err == nil {
-				variationKey := c.calculateVariationKey(evalExp, ff.GetDefaultVariationKey())
+				variationKey := c.evaluator.calculateVariationKey(evalExp, ff.GetDefaultVariationKey())
 				if variationKey != string(types.VariationOff) {
 					arrayIds = append(arrayIds, ff.GetFeatureKey())
 				}
@@ -1464,9 +1661,9 @@ func (c *kameleoonClient) GetActiveFeatures(visitorCode string) (activeFeatures
 			continue
 		}
 		var evalExp *evaluatedExperiment
-		evalExp, err = c.evaluate(visitor, visitorCode, ff, false, false)
+		evalExp, err = c.evaluator.evaluate(visitor, visitorCode, ff, false, false, nil)
 		if err == nil {
-			variationKey := c.calculateVariationKey(evalExp, ff.GetDefaultVariationKey())
+			variationKey := c.evaluator.calculateVariationKey(evalExp, ff.GetDefaultVariationKey())
 			if variationKey == string(types.VariationOff) {
 				continue
 			}
@@ -1504,30 +1701,62 @@ func (c *kameleoonClient) GetEngineTrackingCode(visitorCode string) string {
 
 func (c *kameleoonClient) GetVisitorWarehouseAudience(params VisitorWarehouseAudienceParams) (*types.CustomData, error) {
 	logging.Info("CALL: kameleoonClient.GetVisitorWarehouseAudience(params: %s)", params)
-	customData, err := c.warehouseManager.GetVisitorWarehouseAudience(
+	customData, err := c.warehouseManager.GetVisitorWarehouseAudience(context.Background(),
 		params.VisitorCode, params.WarehouseKey, params.CustomDataIndex, params.Timeout)
 	logging.Info("RETURN: kameleoonClient.GetVisitorWarehouseAudience(params: %s) -> (customData: %s, error: %s)",
 		params, customData, err)
 	return customData, err
 }
 
+func (c *kameleoonClient) GetVisitorWarehouseAudienceCtx(
+	ctx context.Context, params VisitorWarehouseAudienceParams,
+) (*types.CustomData, error) {
+	logging.Info("CALL: kameleoonClient.GetVisitorWarehouseAudienceCtx(ctx, params: %s)", params)
+	customData, err := c.warehouseManager.GetVisitorWarehouseAudience(ctx,
+		params.VisitorCode, params.WarehouseKey, params.CustomDataIndex, params.Timeout)
+	logging.Info(
+		"RETURN: kameleoonClient.GetVisitorWarehouseAudienceCtx(ctx, params: %s) -> (customData: %s, error: %s)",
+		params, customData, err)
+	return customData, err
+}
+
 func (c *kameleoonClient) GetVisitorWarehouseAudienceWithOptParams(
 	visitorCode string, customDataIndex int, params ...VisitorWarehouseAudienceOptParams,
 ) (*types.CustomData, error) {
 	logging.Info(
 		"CALL: kameleoonClient.GetVisitorWarehouseAudienceWithOptParams(visitorCode: %s, customDataIndex: %s, "+
 			"params: %s)", visitorCode, customDataIndex, params)
+	customData, err := c.getVisitorWarehouseAudience(context.Background(), visitorCode, customDataIndex, params...)
+	logging.Info(
+		"RETURN: kameleoonClient.GetVisitorWarehouseAudienceWithOptParams(visitorCode: %s, customDataIndex: %s, "+
+			"params: %s) -> (customData: %s, error: %s)", visitorCode, customDataIndex, params, customData, err)
+	return customData, err
+}
+
+func (c *kameleoonClient) GetVisitorWarehouseAudienceWithOptParamsCtx(
+	ctx context.Context, visitorCode string, customDataIndex int, params ...VisitorWarehouseAudienceOptParams,
+) (*types.CustomData, error) {
+	logging.Info(
+		"CALL: kameleoonClient.GetVisitorWarehouseAudienceWithOptParamsCtx(ctx, visitorCode: %s, "+
+			"customDataIndex: %s, params: %s)", visitorCode, customDataIndex, params)
+	customData, err := c.getVisitorWarehouseAudience(ctx, visitorCode, customDataIndex, params...)
+	logging.Info(
+		"RETURN: kameleoonClient.GetVisitorWarehouseAudienceWithOptParamsCtx(ctx, visitorCode: %s, "+
+			"customDataIndex: %s, params: %s) -> (customData: %s, error: %s)",
+		visitorCode, customDataIndex, params, customData, err)
+	return customData, err
+}
+
+func (c *kameleoonClient) getVisitorWarehouseAudience(
+	ctx context.Context, visitorCode string, customDataIndex int, params ...VisitorWarehouseAudienceOptParams,
+) (*types.CustomData, error) {
 	var p VisitorWarehouseAudienceOptParams
 	if len(params) > 0 {
 		p = params[0]
 	}
-	customData, err := c.warehouseManager.GetVisitorWarehouseAudience(
-		visitorCode, p.WarehouseKey, customDataIndex, p.Timeout,
+	return c.warehouseManager.GetVisitorWarehouseAudience(
+		ctx, visitorCode, p.WarehouseKey, customDataIndex, p.Timeout,
 	)
-	logging.Info(
-		"RETURN: kameleoonClient.GetVisitorWarehouseAudienceWithOptParams(visitorCode: %s, customDataIndex: %s, "+
-			"params: %s) -> (customData: %s, error: %s)", visitorCode, customDataIndex, params, customData, err)
-	return customData, err
 }
 
 func (c *kameleoonClient) setUniqueIdentifier(visitorCode string, isUniqueIdentifier bool) {
@@ -1573,6 +1802,7 @@ func (c *kameleoonClient) SetForcedVariation(
 	} else {
 		visitor.ResetForcedVariation(experimentId)
 	}
+	c.visitorManager.Persist(visitorCode, visitor)
 	return
 }
 
@@ -1591,12 +1821,97 @@ func (c *kameleoonClient) EvaluateAudiences(visitorCode string) (err error) {
 		}
 	}
 	if len(segments) > 0 {
-		c.visitorManager.GetOrCreateVisitor(visitorCode).AddBaseData(true, segments...)
+		visitor := c.visitorManager.GetOrCreateVisitor(visitorCode)
+		visitor.AddBaseData(true, segments...)
+		c.visitorManager.Persist(visitorCode, visitor)
 	}
 	c.trackingManager.AddVisitorCode(visitorCode)
 	return
 }
 
+func (c *kameleoonClient) IsVisitorInSegment(
+	visitorCode string, segmentId int, params ...SegmentOptParams,
+) (matches bool, err error) {
+	logging.Info(
+		"CALL: kameleoonClient.IsVisitorInSegment(visitorCode: %s, segmentId: %s, params: %s)",
+		visitorCode, segmentId, params,
+	)
+	defer func() {
+		logging.Info(
+			"RETURN: kameleoonClient.IsVisitorInSegment(visitorCode: %s, segmentId: %s, params: %s) -> "+
+				"(matches: %s, error: %s)", visitorCode, segmentId, params, matches, err,
+		)
+	}()
+	p := NewSegmentOptParams()
+	if len(params) > 0 {
+		p = params[0]
+	}
+	if err = utils.ValidateVisitorCode(visitorCode); err != nil {
+		return
+	}
+	segment, exists := c.dataManager.DataFile().Segments()[segmentId]
+	if !exists {
+		err = errs.NewSegmentNotFound(segmentId)
+		return
+	}
+	if matches = c.targetingManager.CheckTargeting(visitorCode, 0, segment); matches && p.track {
+		c.trackTargetedSegments(visitorCode, segmentId)
+	}
+	return
+}
+
+func (c *kameleoonClient) GetMatchingSegments(
+	visitorCode string, params ...SegmentOptParams,
+) (segments []types.SegmentInfo, err error) {
+	logging.Info("CALL: kameleoonClient.GetMatchingSegments(visitorCode: %s, params: %s)", visitorCode, params)
+	defer func() {
+		logging.Info(
+			"RETURN: kameleoonClient.GetMatchingSegments(visitorCode: %s, params: %s) -> (segments: %s, error: %s)",
+			visitorCode, params, segments, err,
+		)
+	}()
+	p := NewSegmentOptParams()
+	if len(params) > 0 {
+		p = params[0]
+	}
+	if err = utils.ValidateVisitorCode(visitorCode); err != nil {
+		return
+	}
+	segments = make([]types.SegmentInfo, 0)
+	for id, segment := range c.dataManager.DataFile().Segments() {
+		if c.targetingManager.CheckTargeting(visitorCode, 0, segment) {
+			segments = append(segments, types.SegmentInfo{Id: id, Name: segment.GetSegmentBase().Name})
+		}
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].Id < segments[j].Id })
+	if p.track && (len(segments) > 0) {
+		ids := make([]int, len(segments))
+		for i, segment := range segments {
+			ids[i] = segment.Id
+		}
+		c.trackTargetedSegments(visitorCode, ids...)
+	}
+	return
+}
+
+func (c *kameleoonClient) trackTargetedSegments(visitorCode string, segmentIds ...int) {
+	targetedSegments := make([]types.BaseData, len(segmentIds))
+	for i, id := range segmentIds {
+		targetedSegments[i] = types.NewTargetedSegment(id)
+	}
+	visitor := c.visitorManager.GetOrCreateVisitor(visitorCode)
+	visitor.AddBaseData(true, targetedSegments...)
+	c.visitorManager.Persist(visitorCode, visitor)
+	c.trackingManager.AddVisitorCode(visitorCode)
+}
+
+func (c *kameleoonClient) GetVisitorStorageStats() storage.VisitorStorageStats {
+	logging.Info("CALL: kameleoonClient.GetVisitorStorageStats()")
+	stats := c.visitorManager.Stats()
+	logging.Info("RETURN: kameleoonClient.GetVisitorStorageStats() -> (stats: %+v)", stats)
+	return stats
+}
+
 func (c *kameleoonClient) GetDataFile() types.DataFile {
 	logging.Info("CALL: kameleoonClient.GetDataFile()")
 	internalFeatureFlags := c.dataManager.DataFile().GetFeatureFlags()
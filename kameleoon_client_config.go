@@ -1,12 +1,22 @@
 package kameleoon
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
 	"time"
 
 	"github.com/Kameleoon/client-go/v3/utils"
 
 	"github.com/Kameleoon/client-go/v3/errs"
 	"github.com/Kameleoon/client-go/v3/logging"
+	"github.com/Kameleoon/client-go/v3/managers/tracking"
+	"github.com/Kameleoon/client-go/v3/network"
+	"github.com/Kameleoon/client-go/v3/realtime"
+	"github.com/Kameleoon/client-go/v3/storage"
 	"github.com/cristalhq/aconfig"
 	"github.com/cristalhq/aconfig/aconfigyaml"
 )
@@ -29,18 +39,53 @@ const (
 type KameleoonClientConfig struct {
 	defaultsApplied  bool
 	Network          NetworkConfig
-	Logger           logging.Logger `yml:"-" yaml:"-"`
-	ProxyURL         string         `yml:"proxy_url" yaml:"proxy_url"`
-	ClientID         string         `yml:"client_id" yaml:"client_id"`
-	ClientSecret     string         `yml:"client_secret" yaml:"client_secret"`
-	RefreshInterval  time.Duration  `yml:"refresh_interval" yaml:"refresh_interval" default:"1h"`
-	DefaultTimeout   time.Duration  `yml:"default_timeout" yaml:"default_timeout" default:"10s"`
-	TrackingInterval time.Duration  `yml:"tracking_interval" yaml:"tracking_interval" default:"1s"`
-	VerboseMode      bool           `yml:"verbose_mode" yaml:"verbose_mode"`
-	SessionDuration  time.Duration  `yml:"session_duration" yaml:"session_duration" default:"30m"`
-	TopLevelDomain   string         `yml:"top_level_domain" yaml:"top_level_domain"`
-	Environment      string         `yml:"environment" yaml:"environment"`
-	NetworkDomain    string         `yml:"network_domain" yaml:"network_domain"`
+	Logger           logging.Logger  `yml:"-" yaml:"-"`
+	ProxyURL         string          `yml:"proxy_url" yaml:"proxy_url"`
+	ClientID         string          `yml:"client_id" yaml:"client_id"`
+	ClientSecret     string          `yml:"client_secret" yaml:"client_secret"`
+	RefreshInterval  time.Duration   `yml:"refresh_interval" yaml:"refresh_interval" default:"1h"`
+	DefaultTimeout   time.Duration   `yml:"default_timeout" yaml:"default_timeout" default:"10s"`
+	TrackingInterval time.Duration   `yml:"tracking_interval" yaml:"tracking_interval" default:"1s"`
+	VerboseMode      bool            `yml:"verbose_mode" yaml:"verbose_mode"`
+	SessionDuration  time.Duration   `yml:"session_duration" yaml:"session_duration" default:"30m"`
+	TopLevelDomain   string          `yml:"top_level_domain" yaml:"top_level_domain"`
+	Environment      string          `yml:"environment" yaml:"environment"`
+	NetworkDomain    string          `yml:"network_domain" yaml:"network_domain"`
+	Bootstrap        BootstrapConfig `yml:"bootstrap" yaml:"bootstrap"`
+	// ConfigurationSnapshotDir enables persisting the last fetched configuration to the directory.
+	// The snapshot is loaded on the next start and takes precedence over Bootstrap.
+	ConfigurationSnapshotDir string `yml:"configuration_snapshot_dir" yaml:"configuration_snapshot_dir"`
+	// Offline disables all network I/O. The configuration is taken from the snapshot or Bootstrap only,
+	// remote data methods fail and tracking lines are written to OfflineTrackingWriter or dropped.
+	// Client credentials are not required in offline mode.
+	Offline               bool      `yml:"offline" yaml:"offline"`
+	OfflineTrackingWriter io.Writer `yml:"-" yaml:"-"`
+	// VisitorStore replaces the default in-memory storage of visitors,
+	// e.g. to share visitors between several instances of the application.
+	VisitorStore storage.VisitorStore `yml:"-" yaml:"-"`
+	// MaxVisitors and MaxVisitorsMemory (approximate, in bytes) bound the visitor storage. When a limit is
+	// exceeded, the least recently active visitors are evicted after their unsent data is tracked.
+	// Zero means no limit.
+	MaxVisitors       int   `yml:"max_visitors" yaml:"max_visitors"`
+	MaxVisitorsMemory int64 `yml:"max_visitors_memory" yaml:"max_visitors_memory"`
+	// MaxVisitorsOvershoot is the ratio by which the storage may exceed the limits while it keeps the visitors
+	// whose data is not delivered yet. Past it they are evicted anyway, see GetVisitorStorageStats.
+	// Zero means 0.5.
+	MaxVisitorsOvershoot float64 `yml:"max_visitors_overshoot" yaml:"max_visitors_overshoot"`
+	// TrackingQueueDir enables the write-ahead queue of tracking requests in the directory. Requests which
+	// were not delivered before the process stopped are sent on the next start. The directory must not be
+	// shared between several clients.
+	// Every tracking request is synced to the disk before it is sent, which is one fsync per tracking interval
+	// plus one per FlushVisitorInstantly and instant FlushAll call, so the directory should be on a local disk.
+	TrackingQueueDir string `yml:"tracking_queue_dir" yaml:"tracking_queue_dir"`
+	// TrackingSink receives every tracking event along with the Data API, e.g. to copy the exposures
+	// to a warehouse. If TrackingSinkOnly is set, the events are written to the sink instead of the Data API.
+	// The events of the requests retried from TrackingQueueDir after a restart may be written again.
+	TrackingSink     tracking.TrackingSink `yml:"-" yaml:"-"`
+	TrackingSinkOnly bool                  `yml:"tracking_sink_only" yaml:"tracking_sink_only"`
+	// RealTimeReconnect configures the backoff of the real-time update stream reconnections
+	// and the fallback to polling while the stream is down.
+	RealTimeReconnect realtime.ReconnectPolicy `yml:"real_time_reconnect" yaml:"real_time_reconnect"`
 }
 
 func LoadConfig(path string) (*KameleoonClientConfig, error) {
@@ -54,11 +99,16 @@ func (c *KameleoonClientConfig) defaults() error {
 	}
 	c.defaultsApplied = true
 
-	if len(c.ClientID) == 0 {
-		return errs.NewConfigCredentialsInvalid("Client ID is not specified")
-	}
-	if len(c.ClientSecret) == 0 {
-		return errs.NewConfigCredentialsInvalid("Client secret is not specified")
+	if !c.Offline {
+		if len(c.ClientID) == 0 {
+			return errs.NewConfigCredentialsInvalid("Client ID is not specified")
+		}
+		if len(c.ClientSecret) == 0 {
+			return errs.NewConfigCredentialsInvalid("Client secret is not specified")
+		}
+	} else if !c.Bootstrap.isSet() && (len(c.ConfigurationSnapshotDir) == 0) {
+		logging.Warning("Offline mode is enabled without a bootstrap configuration, " +
+			"all the evaluations will fail as the configuration is empty")
 	}
 
 	if c.RefreshInterval < time.Minute {
@@ -136,11 +186,37 @@ func (c *KameleoonClientConfig) loadFile(configPath string) error {
 	return loader.Load()
 }
 
+// BootstrapConfig describes a local configuration snapshot the client starts from before
+// the first successful fetch. The snapshot must have the same JSON format as the fetched configuration.
+// Only one source is used, in priority order: Data, Reader, FilePath.
+type BootstrapConfig struct {
+	FilePath string    `yml:"file_path" yaml:"file_path"`
+	Reader   io.Reader `yml:"-" yaml:"-"`
+	Data     []byte    `yml:"-" yaml:"-"`
+}
+
+func (c *BootstrapConfig) isSet() bool {
+	return (len(c.Data) > 0) || (c.Reader != nil) || (len(c.FilePath) > 0)
+}
+
+func (c *BootstrapConfig) read() ([]byte, error) {
+	switch {
+	case len(c.Data) > 0:
+		return c.Data, nil
+	case c.Reader != nil:
+		return io.ReadAll(c.Reader)
+	default:
+		return os.ReadFile(c.FilePath)
+	}
+}
+
 const (
 	DefaultReadTimeout     = 5 * time.Second
 	DefaultWriteTimeout    = 5 * time.Second
 	DefaultDoTimeout       = 10 * time.Second
 	DefaultMaxConnsPerHost = 10000
+	// DefaultSseHeartbeatTimeout is long enough to not reconnect a quiet but healthy real-time stream too often
+	DefaultSseHeartbeatTimeout = 5 * time.Minute
 )
 
 type NetworkConfig struct {
@@ -149,6 +225,35 @@ type NetworkConfig struct {
 	ReadTimeout     time.Duration
 	WriteTimeout    time.Duration
 	MaxConnsPerHost int
+	// SseHeartbeatTimeout is the longest silence of the real-time update stream, after which it is reconnected.
+	// Negative value disables the check.
+	SseHeartbeatTimeout time.Duration
+	// EndpointOverride is a base URL (scheme://host[:port]) used for all the Kameleoon services instead of
+	// their domains. It is intended for testing against a local server, see kameleoontest/server.
+	EndpointOverride string
+	TLS              TLSConfig
+	// Retry configures the repetition of the failed requests per endpoint class,
+	// zero values are replaced with the defaults of the class.
+	Retry network.RetryPolicies
+	// CircuitBreaker configures the per-host circuit breakers which make the requests fail fast
+	// while a Kameleoon endpoint is unhealthy.
+	CircuitBreaker network.CircuitBreakerConfig
+	// NetProvider replaces the HTTP client of the requests to Kameleoon, the real-time update stream excluded.
+	// The stream goes through HTTPTransport if it is set as well.
+	NetProvider network.NetProvider `yml:"-" yaml:"-"`
+	// HTTPTransport makes the requests and the real-time update stream go through net/http with the transport,
+	// e.g. a RoundTripper chain which enforces the outbound policy. ProxyURL, MaxConnsPerHost and TLS are not
+	// applied to it.
+	HTTPTransport http.RoundTripper `yml:"-" yaml:"-"`
+	// UseNetHTTP makes the requests go through net/http instead of fasthttp, with HTTP/2 support
+	// and the proxy taken from the environment unless ProxyURL is set.
+	UseNetHTTP bool
+	// TrackingCompression is the content encoding of the tracking request bodies, "gzip" or "zstd".
+	// The request size limit is measured after compression, so more visitors fit in one request.
+	// The tracking is not compressed by default.
+	TrackingCompression network.Compression
+
+	tlsConfig *tls.Config
 }
 
 func (c *NetworkConfig) defaults() error {
@@ -164,5 +269,84 @@ func (c *NetworkConfig) defaults() error {
 	if c.MaxConnsPerHost == 0 {
 		c.MaxConnsPerHost = DefaultMaxConnsPerHost
 	}
-	return nil
+	if c.SseHeartbeatTimeout == 0 {
+		c.SseHeartbeatTimeout = DefaultSseHeartbeatTimeout
+	}
+	if !c.TrackingCompression.IsValid() {
+		return errs.NewConfigCompressionInvalid(
+			fmt.Sprintf("Unsupported tracking compression '%s'", c.TrackingCompression))
+	}
+	var err error
+	c.tlsConfig, err = c.TLS.build()
+	return err
+}
+
+// TLSConfig configures TLS of all the connections to Kameleoon, including the real-time update stream.
+// The server certificates are verified against the system root CAs by default.
+type TLSConfig struct {
+	// RootCAFile is a PEM bundle of the CAs trusted instead of the system ones,
+	// e.g. the CA of a TLS-inspecting corporate proxy.
+	RootCAFile string `yml:"root_ca_file" yaml:"root_ca_file"`
+	// RootCAsPEM are PEM-encoded CAs trusted along with the ones of RootCAFile instead of the system ones.
+	RootCAsPEM []byte `yml:"-" yaml:"-"`
+	// ClientCertFile and ClientKeyFile are the PEM certificate and key presented for mutual TLS.
+	ClientCertFile string `yml:"client_cert_file" yaml:"client_cert_file"`
+	ClientKeyFile  string `yml:"client_key_file" yaml:"client_key_file"`
+	// ClientCertificates are presented for mutual TLS along with the certificate of ClientCertFile.
+	ClientCertificates []tls.Certificate `yml:"-" yaml:"-"`
+	// MinVersion is the minimal accepted TLS version, "1.2" (default) or "1.3".
+	MinVersion string `yml:"min_version" yaml:"min_version"`
+	// ServerName overrides the name which the server certificates are verified for (and which is sent with SNI),
+	// e.g. if EndpointOverride addresses the server by IP.
+	ServerName string `yml:"server_name" yaml:"server_name"`
+	// InsecureSkipVerify disables the verification of the server certificates, which makes the connections
+	// vulnerable to man-in-the-middle attacks. It must never be enabled in production.
+	InsecureSkipVerify bool `yml:"insecure_skip_verify" yaml:"insecure_skip_verify"`
+}
+
+func (c *TLSConfig) build() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		ServerName:   c.ServerName,
+		Certificates: append([]tls.Certificate(nil), c.ClientCertificates...),
+	}
+	switch c.MinVersion {
+	case "", "1.2":
+	case "1.3":
+		tlsConfig.MinVersion = tls.VersionTLS13
+	default:
+		return nil, errs.NewConfigTLSInvalid(fmt.Sprintf("Unsupported minimal TLS version '%s'", c.MinVersion))
+	}
+	if len(c.RootCAFile) > 0 {
+		pem, err := os.ReadFile(c.RootCAFile)
+		if err != nil {
+			return nil, errs.NewConfigTLSInvalid(fmt.Sprintf("Failed to read the root CA file: %s", err))
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errs.NewConfigTLSInvalid(
+				fmt.Sprintf("No certificate is found in the root CA file %s", c.RootCAFile))
+		}
+	}
+	if len(c.RootCAsPEM) > 0 {
+		if tlsConfig.RootCAs == nil {
+			tlsConfig.RootCAs = x509.NewCertPool()
+		}
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(c.RootCAsPEM) {
+			return nil, errs.NewConfigTLSInvalid("No certificate is found in RootCAsPEM")
+		}
+	}
+	if (len(c.ClientCertFile) > 0) || (len(c.ClientKeyFile) > 0) {
+		cert, err := tls.LoadX509KeyPair(c.ClientCertFile, c.ClientKeyFile)
+		if err != nil {
+			return nil, errs.NewConfigTLSInvalid(fmt.Sprintf("Failed to load the client certificate: %s", err))
+		}
+		tlsConfig.Certificates = append(tlsConfig.Certificates, cert)
+	}
+	if c.InsecureSkipVerify {
+		logging.Error("TLS certificate verification is DISABLED (InsecureSkipVerify). The connections to " +
+			"Kameleoon are vulnerable to man-in-the-middle attacks, never use this setting in production.")
+		tlsConfig.InsecureSkipVerify = true
+	}
+	return tlsConfig, nil
 }
@@ -1,11 +1,16 @@
 package kameleoon
 
-import "sync"
+import (
+	"context"
+	"sync"
+)
 
 type kameleoonClientReadiness struct {
 	isInitializing bool
 	err            error
 	cond           sync.RWMutex
+	mx             sync.Mutex // guards readyChan, which is replaced on reset
+	readyChan      chan struct{}
 }
 
 func newKameleoonClientReadiness() *kameleoonClientReadiness {
@@ -18,6 +23,9 @@ func (r *kameleoonClientReadiness) reset() {
 	r.err = nil
 	if !r.isInitializing {
 		r.isInitializing = true
+		r.mx.Lock()
+		r.readyChan = make(chan struct{})
+		r.mx.Unlock()
 		r.cond.Lock()
 	}
 }
@@ -26,6 +34,9 @@ func (r *kameleoonClientReadiness) set(err error) {
 	if r.isInitializing {
 		r.cond.Unlock()
 		r.isInitializing = false
+		r.mx.Lock()
+		close(r.readyChan)
+		r.mx.Unlock()
 	}
 }
 
@@ -44,3 +55,15 @@ func (r *kameleoonClientReadiness) Wait() error {
 	}
 	return r.err
 }
+
+func (r *kameleoonClientReadiness) WaitCtx(ctx context.Context) error {
+	r.mx.Lock()
+	readyChan := r.readyChan
+	r.mx.Unlock()
+	select {
+	case <-readyChan:
+		return r.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,826 @@
+// Package kameleoontest provides test doubles for code which depends on kameleoon.KameleoonClient.
+package kameleoontest
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	kameleoon "github.com/Kameleoon/client-go/v3"
+	"github.com/Kameleoon/client-go/v3/configuration"
+	"github.com/Kameleoon/client-go/v3/errs"
+	"github.com/Kameleoon/client-go/v3/storage"
+	"github.com/Kameleoon/client-go/v3/types"
+	"github.com/Kameleoon/client-go/v3/utils"
+	"github.com/valyala/fasthttp"
+)
+
+const visitorCodeCookie = "kameleoonVisitorCode"
+
+var _ kameleoon.KameleoonClient = (*FakeClient)(nil)
+
+// AddDataCall is a recorded call of AddData or AddDataWithOptParams.
+type AddDataCall struct {
+	VisitorCode string
+	Data        []types.Data
+	Params      kameleoon.AddDataOptParams
+}
+
+// ConversionCall is a recorded call of one of the TrackConversion methods.
+type ConversionCall struct {
+	VisitorCode string
+	GoalId      int
+	Params      kameleoon.TrackConversionOptParams
+}
+
+// FlushCall is a recorded call of one of the Flush methods.
+// VisitorCode is empty for FlushAll calls.
+type FlushCall struct {
+	VisitorCode string
+	Instant     bool
+	All         bool
+}
+
+// ForcedVariationCall is a recorded call of SetForcedVariation.
+type ForcedVariationCall struct {
+	VisitorCode    string
+	ExperimentId   int
+	VariationKey   string
+	ForceTargeting bool
+}
+
+// FakeClient is an in-memory KameleoonClient which needs neither credentials nor network.
+//
+// Variations are resolved from the values set with SetVariation, then SetDefaultVariation,
+// falling back to the "off" variation. Features which were not added with AddFeature
+// are reported as not found. Calls which change the visitor state are recorded.
+//
+// FakeClient is safe for concurrent use.
+type FakeClient struct {
+	mx sync.Mutex
+
+	features          []string
+	defaultVariations map[string]string
+	visitorVariations map[string]map[string]string
+	variables         map[string]map[string]map[string]interface{}
+	remoteData        map[string][]byte
+	remoteVisitorData map[string][]types.Data
+	legalConsents     map[string]bool
+	visitorSegments   map[string][]types.SegmentInfo
+	configHandler     func()
+	configSubscribers map[uint64]func(configuration.ConfigurationChange)
+	nextSubscriberId  uint64
+
+	addDataCalls         []AddDataCall
+	conversionCalls      []ConversionCall
+	flushCalls           []FlushCall
+	forcedVariationCalls []ForcedVariationCall
+	trackedVisitorCodes  []string
+	closed               bool
+}
+
+func NewFakeClient() *FakeClient {
+	return &FakeClient{
+		defaultVariations: make(map[string]string),
+		visitorVariations: make(map[string]map[string]string),
+		variables:         make(map[string]map[string]map[string]interface{}),
+		remoteData:        make(map[string][]byte),
+		remoteVisitorData: make(map[string][]types.Data),
+		legalConsents:     make(map[string]bool),
+		visitorSegments:   make(map[string][]types.SegmentInfo),
+		configSubscribers: make(map[uint64]func(configuration.ConfigurationChange)),
+	}
+}
+
+// configuration
+
+// AddFeature adds the feature flags to the feature list.
+func (f *FakeClient) AddFeature(featureKeys ...string) *FakeClient {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	for _, featureKey := range featureKeys {
+		if !f.hasFeature(featureKey) {
+			f.features = append(f.features, featureKey)
+		}
+	}
+	return f
+}
+
+// SetDefaultVariation sets the variation returned for every visitor without a forced variation.
+// The feature flag is added to the feature list if it is missing.
+func (f *FakeClient) SetDefaultVariation(featureKey string, variationKey string) *FakeClient {
+	f.AddFeature(featureKey)
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	f.defaultVariations[featureKey] = variationKey
+	return f
+}
+
+// SetVariation forces the variation of the feature flag for the visitor.
+// The feature flag is added to the feature list if it is missing.
+func (f *FakeClient) SetVariation(visitorCode string, featureKey string, variationKey string) *FakeClient {
+	f.AddFeature(featureKey)
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	vs, ok := f.visitorVariations[visitorCode]
+	if !ok {
+		vs = make(map[string]string)
+		f.visitorVariations[visitorCode] = vs
+	}
+	vs[featureKey] = variationKey
+	return f
+}
+
+// SetVariables sets the variables of the variation of the feature flag.
+func (f *FakeClient) SetVariables(
+	featureKey string, variationKey string, variables map[string]interface{},
+) *FakeClient {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	vs, ok := f.variables[featureKey]
+	if !ok {
+		vs = make(map[string]map[string]interface{})
+		f.variables[featureKey] = vs
+	}
+	vs[variationKey] = variables
+	return f
+}
+
+// SetRemoteData sets the value returned by GetRemoteData for the key.
+func (f *FakeClient) SetRemoteData(key string, data []byte) *FakeClient {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	f.remoteData[key] = data
+	return f
+}
+
+// SetRemoteVisitorData sets the value returned by the GetRemoteVisitorData methods for the visitor.
+func (f *FakeClient) SetRemoteVisitorData(visitorCode string, data ...types.Data) *FakeClient {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	f.remoteVisitorData[visitorCode] = data
+	return f
+}
+
+// SetVisitorSegments sets the segments which the visitor matches.
+func (f *FakeClient) SetVisitorSegments(visitorCode string, segments ...types.SegmentInfo) *FakeClient {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	f.visitorSegments[visitorCode] = append([]types.SegmentInfo(nil), segments...)
+	return f
+}
+
+// recorded calls
+
+func (f *FakeClient) AddDataCalls() []AddDataCall {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	return append([]AddDataCall(nil), f.addDataCalls...)
+}
+
+func (f *FakeClient) ConversionCalls() []ConversionCall {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	return append([]ConversionCall(nil), f.conversionCalls...)
+}
+
+func (f *FakeClient) FlushCalls() []FlushCall {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	return append([]FlushCall(nil), f.flushCalls...)
+}
+
+func (f *FakeClient) ForcedVariationCalls() []ForcedVariationCall {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	return append([]ForcedVariationCall(nil), f.forcedVariationCalls...)
+}
+
+// TrackedVisitorCodes returns the visitor codes of the evaluations which were made with tracking.
+func (f *FakeClient) TrackedVisitorCodes() []string {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	return append([]string(nil), f.trackedVisitorCodes...)
+}
+
+// LegalConsent returns the legal consent set for the visitor and whether it was set.
+func (f *FakeClient) LegalConsent(visitorCode string) (consent bool, ok bool) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	consent, ok = f.legalConsents[visitorCode]
+	return
+}
+
+// Closed reports whether Close was called.
+func (f *FakeClient) Closed() bool {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	return f.closed
+}
+
+// ResetCalls forgets all the recorded calls. The configuration is kept.
+func (f *FakeClient) ResetCalls() {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	f.addDataCalls = nil
+	f.conversionCalls = nil
+	f.flushCalls = nil
+	f.forcedVariationCalls = nil
+	f.trackedVisitorCodes = nil
+	f.legalConsents = make(map[string]bool)
+}
+
+// TriggerConfigurationUpdate calls the handler registered with OnUpdateConfiguration
+// and the subscribers with a change from the real-time stream.
+func (f *FakeClient) TriggerConfigurationUpdate() {
+	f.TriggerConfigurationChange(configuration.ConfigurationChange{Source: configuration.ConfigurationChangeSourceSse})
+}
+
+// TriggerConfigurationChange calls the handlers registered with SubscribeConfigurationChanges with the change.
+// The handler registered with OnUpdateConfiguration is called as well if the change comes from the real-time stream.
+func (f *FakeClient) TriggerConfigurationChange(change configuration.ConfigurationChange) {
+	f.mx.Lock()
+	handler := f.configHandler
+	subscribers := make([]func(configuration.ConfigurationChange), 0, len(f.configSubscribers))
+	for _, subscriber := range f.configSubscribers {
+		subscribers = append(subscribers, subscriber)
+	}
+	f.mx.Unlock()
+	if (handler != nil) && (change.Source == configuration.ConfigurationChangeSourceSse) {
+		handler()
+	}
+	for _, subscriber := range subscribers {
+		subscriber(change)
+	}
+}
+
+// helpers, must be called under the lock
+
+func (f *FakeClient) hasFeature(featureKey string) bool {
+	for _, key := range f.features {
+		if key == featureKey {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *FakeClient) variationKey(visitorCode string, featureKey string) (string, error) {
+	if err := utils.ValidateVisitorCode(visitorCode); err != nil {
+		return "", err
+	}
+	if !f.hasFeature(featureKey) {
+		return "", errs.NewFeatureNotFound(featureKey)
+	}
+	if variationKey, ok := f.visitorVariations[visitorCode][featureKey]; ok {
+		return variationKey, nil
+	}
+	if variationKey, ok := f.defaultVariations[featureKey]; ok {
+		return variationKey, nil
+	}
+	return string(types.VariationOff), nil
+}
+
+func (f *FakeClient) variation(visitorCode string, featureKey string, track bool) (types.Variation, error) {
+	variationKey, err := f.variationKey(visitorCode, featureKey)
+	if err != nil {
+		return types.Variation{}, err
+	}
+	if track {
+		f.trackedVisitorCodes = append(f.trackedVisitorCodes, visitorCode)
+	}
+	variables := make(map[string]types.Variable)
+	for key, value := range f.variables[featureKey][variationKey] {
+		variables[key] = types.Variable{Key: key, Value: value}
+	}
+	return types.Variation{Key: variationKey, Variables: variables}, nil
+}
+
+func (f *FakeClient) variations(visitorCode string, onlyActive bool, track bool) (map[string]types.Variation, error) {
+	if err := utils.ValidateVisitorCode(visitorCode); err != nil {
+		return nil, err
+	}
+	variations := make(map[string]types.Variation, len(f.features))
+	for _, featureKey := range f.features {
+		variation, err := f.variation(visitorCode, featureKey, false)
+		if err != nil {
+			return nil, err
+		}
+		if onlyActive && !variation.IsActive() {
+			continue
+		}
+		variations[featureKey] = variation
+	}
+	if track {
+		f.trackedVisitorCodes = append(f.trackedVisitorCodes, visitorCode)
+	}
+	return variations, nil
+}
+
+// KameleoonClient
+
+func (f *FakeClient) WaitInit() error {
+	return nil
+}
+
+func (f *FakeClient) WaitInitCtx(ctx context.Context) error {
+	return ctx.Err()
+}
+
+func (f *FakeClient) Close(ctx context.Context) error {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	f.closed = true
+	return nil
+}
+
+func (f *FakeClient) GetVisitorCode(
+	request *fasthttp.Request, response *fasthttp.Response, defaultVisitorCode ...string,
+) (string, error) {
+	var visitorCode string
+	if value := request.Header.Cookie(visitorCodeCookie); value != nil {
+		visitorCode = string(value)
+	} else if len(defaultVisitorCode) > 0 {
+		visitorCode = defaultVisitorCode[0]
+	} else {
+		return utils.GenerateVisitorCode(), nil
+	}
+	return visitorCode, utils.ValidateVisitorCode(visitorCode)
+}
+
+func (f *FakeClient) SetLegalConsent(visitorCode string, consent bool, response ...*fasthttp.Response) error {
+	return f.setLegalConsent(visitorCode, consent)
+}
+
+func (f *FakeClient) GetVisitorCodeHTTP(
+	request *http.Request, response http.ResponseWriter, defaultVisitorCode ...string,
+) (string, error) {
+	var visitorCode string
+	if ck, err := request.Cookie(visitorCodeCookie); err == nil {
+		visitorCode = ck.Value
+	} else if len(defaultVisitorCode) > 0 {
+		visitorCode = defaultVisitorCode[0]
+	} else {
+		return utils.GenerateVisitorCode(), nil
+	}
+	return visitorCode, utils.ValidateVisitorCode(visitorCode)
+}
+
+func (f *FakeClient) SetLegalConsentHTTP(visitorCode string, consent bool, response ...http.ResponseWriter) error {
+	return f.setLegalConsent(visitorCode, consent)
+}
+
+func (f *FakeClient) setLegalConsent(visitorCode string, consent bool) error {
+	if err := utils.ValidateVisitorCode(visitorCode); err != nil {
+		return err
+	}
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	f.legalConsents[visitorCode] = consent
+	return nil
+}
+
+func (f *FakeClient) AddData(visitorCode string, allData ...types.Data) error {
+	return f.AddDataWithOptParams(visitorCode, kameleoon.NewAddDataOptParams(), allData...)
+}
+
+func (f *FakeClient) AddDataWithOptParams(
+	visitorCode string, optParams kameleoon.AddDataOptParams, allData ...types.Data,
+) error {
+	if err := utils.ValidateVisitorCode(visitorCode); err != nil {
+		return err
+	}
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	f.addDataCalls = append(f.addDataCalls, AddDataCall{
+		VisitorCode: visitorCode,
+		Data:        append([]types.Data(nil), allData...),
+		Params:      optParams,
+	})
+	return nil
+}
+
+func (f *FakeClient) TrackConversion(visitorCode string, goalID int, isUniqueIdentifier ...bool) error {
+	return f.TrackConversionWithOptParams(visitorCode, goalID, kameleoon.TrackConversionOptParams{})
+}
+
+func (f *FakeClient) TrackConversionRevenue(
+	visitorCode string, goalID int, revenue float64, isUniqueIdentifier ...bool,
+) error {
+	return f.TrackConversionWithOptParams(visitorCode, goalID, kameleoon.TrackConversionOptParams{Revenue: revenue})
+}
+
+func (f *FakeClient) TrackConversionWithOptParams(
+	visitorCode string, goalId int, params kameleoon.TrackConversionOptParams,
+) error {
+	if err := utils.ValidateVisitorCode(visitorCode); err != nil {
+		return err
+	}
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	f.conversionCalls = append(f.conversionCalls, ConversionCall{VisitorCode: visitorCode, GoalId: goalId, Params: params})
+	return nil
+}
+
+func (f *FakeClient) FlushVisitor(visitorCode string, isUniqueIdentifier ...bool) error {
+	return f.flushVisitor(visitorCode, false)
+}
+
+func (f *FakeClient) FlushVisitorInstantly(visitorCode string) error {
+	return f.flushVisitor(visitorCode, true)
+}
+
+func (f *FakeClient) flushVisitor(visitorCode string, instant bool) error {
+	if err := utils.ValidateVisitorCode(visitorCode); err != nil {
+		return err
+	}
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	f.flushCalls = append(f.flushCalls, FlushCall{VisitorCode: visitorCode, Instant: instant})
+	return nil
+}
+
+func (f *FakeClient) FlushAll(instant ...bool) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	f.flushCalls = append(f.flushCalls, FlushCall{Instant: (len(instant) > 0) && instant[0], All: true})
+}
+
+func (f *FakeClient) GetFeatureVariationKey(
+	visitorCode string, featureKey string, isUniqueIdentifier ...bool,
+) (string, error) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	variation, err := f.variation(visitorCode, featureKey, true)
+	return variation.Key, err
+}
+
+func (f *FakeClient) GetFeatureVariable(
+	visitorCode string, featureKey string, variableKey string, isUniqueIdentifier ...bool,
+) (interface{}, error) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	variation, err := f.variation(visitorCode, featureKey, true)
+	if err != nil {
+		return nil, err
+	}
+	variable, ok := variation.Variables[variableKey]
+	if !ok {
+		return nil, errs.NewFeatureVariableNotFound(featureKey, variation.Key, variableKey)
+	}
+	return variable.Value, nil
+}
+
+func (f *FakeClient) IsFeatureActive(visitorCode string, featureKey string, isUniqueIdentifier ...bool) (bool, error) {
+	return f.IsFeatureActiveWithTracking(visitorCode, featureKey, true)
+}
+
+func (f *FakeClient) IsFeatureActiveWithTracking(visitorCode string, featureKey string, track bool) (bool, error) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	variation, err := f.variation(visitorCode, featureKey, track)
+	return variation.IsActive(), err
+}
+
+func (f *FakeClient) GetVariation(
+	visitorCode string, featureKey string, params ...kameleoon.GetVariationOptParams,
+) (types.Variation, error) {
+	track := (len(params) == 0) || params[0].IsTracked()
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	return f.variation(visitorCode, featureKey, track)
+}
+
+func (f *FakeClient) GetVariations(
+	visitorCode string, params ...kameleoon.GetVariationsOptParams,
+) (map[string]types.Variation, error) {
+	onlyActive, track := false, true
+	if len(params) > 0 {
+		onlyActive, track = params[0].IsOnlyActive(), params[0].IsTracked()
+	}
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	return f.variations(visitorCode, onlyActive, track)
+}
+
+func (f *FakeClient) GetVariationCtx(
+	ctx context.Context, visitorCode string, featureKey string, params ...kameleoon.GetVariationOptParams,
+) (types.Variation, error) {
+	if err := ctx.Err(); err != nil {
+		return types.Variation{}, err
+	}
+	return f.GetVariation(visitorCode, featureKey, params...)
+}
+
+func (f *FakeClient) GetVariationsCtx(
+	ctx context.Context, visitorCode string, params ...kameleoon.GetVariationsOptParams,
+) (map[string]types.Variation, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return f.GetVariations(visitorCode, params...)
+}
+
+func (f *FakeClient) GetFeatureVariationVariables(
+	featureKey string, variationKey string,
+) (map[string]interface{}, error) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	if !f.hasFeature(featureKey) {
+		return nil, errs.NewFeatureNotFound(featureKey)
+	}
+	variables := make(map[string]interface{})
+	for key, value := range f.variables[featureKey][variationKey] {
+		variables[key] = value
+	}
+	return variables, nil
+}
+
+func (f *FakeClient) GetRemoteData(key string, timeout ...time.Duration) ([]byte, error) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	return f.remoteData[key], nil
+}
+
+func (f *FakeClient) GetRemoteDataCtx(ctx context.Context, key string, timeout ...time.Duration) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return f.GetRemoteData(key, timeout...)
+}
+
+func (f *FakeClient) GetVisitorWarehouseAudience(
+	params kameleoon.VisitorWarehouseAudienceParams,
+) (*types.CustomData, error) {
+	return nil, utils.ValidateVisitorCode(params.VisitorCode)
+}
+
+func (f *FakeClient) GetVisitorWarehouseAudienceCtx(
+	ctx context.Context, params kameleoon.VisitorWarehouseAudienceParams,
+) (*types.CustomData, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return f.GetVisitorWarehouseAudience(params)
+}
+
+func (f *FakeClient) GetVisitorWarehouseAudienceWithOptParams(
+	visitorCode string, customDataIndex int, params ...kameleoon.VisitorWarehouseAudienceOptParams,
+) (*types.CustomData, error) {
+	return nil, utils.ValidateVisitorCode(visitorCode)
+}
+
+func (f *FakeClient) GetVisitorWarehouseAudienceWithOptParamsCtx(
+	ctx context.Context, visitorCode string, customDataIndex int, params ...kameleoon.VisitorWarehouseAudienceOptParams,
+) (*types.CustomData, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return f.GetVisitorWarehouseAudienceWithOptParams(visitorCode, customDataIndex, params...)
+}
+
+func (f *FakeClient) GetRemoteVisitorData(
+	visitorCode string, addData bool, timeout ...time.Duration,
+) ([]types.Data, error) {
+	if err := utils.ValidateVisitorCode(visitorCode); err != nil {
+		return nil, err
+	}
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	return append([]types.Data(nil), f.remoteVisitorData[visitorCode]...), nil
+}
+
+func (f *FakeClient) GetRemoteVisitorDataCtx(
+	ctx context.Context, visitorCode string, addData bool, timeout ...time.Duration,
+) ([]types.Data, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return f.GetRemoteVisitorData(visitorCode, addData, timeout...)
+}
+
+func (f *FakeClient) GetRemoteVisitorDataWithOptParams(
+	visitorCode string, addData bool, filter types.RemoteVisitorDataFilter,
+	params ...kameleoon.RemoteVisitorDataOptParams,
+) ([]types.Data, error) {
+	return f.GetRemoteVisitorData(visitorCode, addData)
+}
+
+func (f *FakeClient) GetRemoteVisitorDataWithFilter(
+	visitorCode string, addData bool, filter types.RemoteVisitorDataFilter,
+	params ...kameleoon.RemoteVisitorDataOptParams,
+) ([]types.Data, error) {
+	return f.GetRemoteVisitorData(visitorCode, addData)
+}
+
+func (f *FakeClient) GetRemoteVisitorDataWithFilterCtx(
+	ctx context.Context,
+	visitorCode string,
+	addData bool,
+	filter types.RemoteVisitorDataFilter,
+	params ...kameleoon.RemoteVisitorDataOptParams,
+) ([]types.Data, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return f.GetRemoteVisitorData(visitorCode, addData)
+}
+
+func (f *FakeClient) OnUpdateConfiguration(handler func()) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	f.configHandler = handler
+}
+
+func (f *FakeClient) SubscribeConfigurationChanges(handler func(configuration.ConfigurationChange)) func() {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	id := f.nextSubscriberId
+	f.nextSubscriberId++
+	f.configSubscribers[id] = handler
+	return func() {
+		f.mx.Lock()
+		defer f.mx.Unlock()
+		delete(f.configSubscribers, id)
+	}
+}
+
+func (f *FakeClient) GetFeatureList() []string {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	return append([]string(nil), f.features...)
+}
+
+func (f *FakeClient) GetActiveFeatureListForVisitor(visitorCode string) ([]string, error) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	variations, err := f.variations(visitorCode, true, false)
+	if err != nil {
+		return nil, err
+	}
+	featureKeys := make([]string, 0, len(variations))
+	for featureKey := range variations {
+		featureKeys = append(featureKeys, featureKey)
+	}
+	sort.Strings(featureKeys)
+	return featureKeys, nil
+}
+
+func (f *FakeClient) GetActiveFeatures(visitorCode string) (map[string]types.Variation, error) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	return f.variations(visitorCode, true, false)
+}
+
+func (f *FakeClient) GetEngineTrackingCode(visitorCode string) string {
+	return ""
+}
+
+func (f *FakeClient) SetForcedVariation(
+	visitorCode string, experimentId int, variationKey string, params ...kameleoon.SetForcedVariationOptParams,
+) error {
+	if err := utils.ValidateVisitorCode(visitorCode); err != nil {
+		return err
+	}
+	forceTargeting := (len(params) == 0) || params[0].IsTargetingForced()
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	f.forcedVariationCalls = append(f.forcedVariationCalls, ForcedVariationCall{
+		VisitorCode:    visitorCode,
+		ExperimentId:   experimentId,
+		VariationKey:   variationKey,
+		ForceTargeting: forceTargeting,
+	})
+	return nil
+}
+
+func (f *FakeClient) EvaluateAudiences(visitorCode string) error {
+	return utils.ValidateVisitorCode(visitorCode)
+}
+
+// IsVisitorInSegment reports whether the segment was set for the visitor with SetVisitorSegments.
+// Unknown segments are not reported as errors because the fake client has no configuration.
+func (f *FakeClient) IsVisitorInSegment(
+	visitorCode string, segmentId int, params ...kameleoon.SegmentOptParams,
+) (bool, error) {
+	if err := utils.ValidateVisitorCode(visitorCode); err != nil {
+		return false, err
+	}
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	for _, segment := range f.visitorSegments[visitorCode] {
+		if segment.Id == segmentId {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *FakeClient) GetMatchingSegments(
+	visitorCode string, params ...kameleoon.SegmentOptParams,
+) ([]types.SegmentInfo, error) {
+	if err := utils.ValidateVisitorCode(visitorCode); err != nil {
+		return nil, err
+	}
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	segments := append([]types.SegmentInfo{}, f.visitorSegments[visitorCode]...)
+	sort.Slice(segments, func(i, j int) bool { return segments[i].Id < segments[j].Id })
+	return segments, nil
+}
+
+// GetVariationStateless resolves the configured variation, the visitor data is ignored.
+// No sendable data is returned and nothing is tracked.
+func (f *FakeClient) GetVariationStateless(
+	visitorCode string, featureKey string, visitorData []types.BaseData, params ...kameleoon.StatelessOptParams,
+) (types.Variation, []types.Sendable, error) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	variation, err := f.variation(visitorCode, featureKey, false)
+	return variation, nil, err
+}
+
+// GetVariationsStateless resolves the configured variations, the visitor data is ignored.
+// No sendable data is returned and nothing is tracked.
+func (f *FakeClient) GetVariationsStateless(
+	visitorCode string, visitorData []types.BaseData, params ...kameleoon.StatelessOptParams,
+) (map[string]types.Variation, []types.Sendable, error) {
+	onlyActive := (len(params) > 0) && params[0].IsOnlyActive()
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	variations, err := f.variations(visitorCode, onlyActive, false)
+	return variations, nil, err
+}
+
+// EvaluateBatch resolves the configured variations sequentially. Without feature keys all the added features
+// are evaluated.
+func (f *FakeClient) EvaluateBatch(
+	ctx context.Context, visitorCodes []string, featureKeys []string, params ...kameleoon.EvaluateBatchOptParams,
+) (*types.BatchEvaluationResult, error) {
+	p := kameleoon.NewEvaluateBatchOptParams()
+	if len(params) > 0 {
+		p = params[0]
+	}
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	if len(featureKeys) == 0 {
+		featureKeys = append([]string(nil), f.features...)
+	}
+	for _, featureKey := range featureKeys {
+		if !f.hasFeature(featureKey) {
+			return nil, errs.NewFeatureNotFound(featureKey)
+		}
+	}
+	result := &types.BatchEvaluationResult{VisitorCodes: visitorCodes, FeatureKeys: featureKeys}
+	onResult := p.ResultCallback()
+	if onResult == nil {
+		result.VariationKeys = make([][]string, len(visitorCodes))
+		result.Errors = make([]error, len(visitorCodes))
+		onResult = func(r types.BatchVisitorResult) {
+			result.VariationKeys[r.Index] = r.VariationKeys
+			result.Errors[r.Index] = r.Err
+		}
+	}
+	for index, visitorCode := range visitorCodes {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		row := types.BatchVisitorResult{Index: index, VisitorCode: visitorCode}
+		if row.Err = utils.ValidateVisitorCode(visitorCode); row.Err == nil {
+			row.VariationKeys = make([]string, len(featureKeys))
+			for i, featureKey := range featureKeys {
+				row.VariationKeys[i], _ = f.variationKey(visitorCode, featureKey)
+			}
+			if p.IsTracked() {
+				f.trackedVisitorCodes = append(f.trackedVisitorCodes, visitorCode)
+			}
+		}
+		onResult(row)
+	}
+	return result, nil
+}
+
+// ExplainVariation explains the configured variation as the only step. Nothing is tracked.
+func (f *FakeClient) ExplainVariation(visitorCode string, featureKey string) (*types.VariationExplanation, error) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	variationKey, err := f.variationKey(visitorCode, featureKey)
+	if err != nil {
+		return nil, err
+	}
+	return &types.VariationExplanation{
+		VisitorCode:        visitorCode,
+		FeatureKey:         featureKey,
+		VariationKey:       variationKey,
+		EnvironmentEnabled: true,
+		BucketingKey:       visitorCode,
+		BucketingKeySource: types.BucketingKeySourceVisitorCode,
+	}, nil
+}
+
+// GetVisitorStorageStats returns zero stats: the fake client doesn't store visitors.
+func (f *FakeClient) GetVisitorStorageStats() storage.VisitorStorageStats {
+	return storage.VisitorStorageStats{}
+}
@@ -0,0 +1,303 @@
+// Package server provides a local stand-in for the Kameleoon services which the SDK talks to.
+//
+// Point a client at it with KameleoonClientConfig.Network.EndpointOverride set to Server.URL().
+package server
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	configurationUpdateEvent = "configuration-update-event"
+	defaultAccessToken       = "kameleoontest-token"
+	accessTokenLifetime      = 3600 // in seconds
+)
+
+// Server is an httptest server which implements the SDK configuration, real-time update (SSE),
+// OAuth and Data API endpoints used by the SDK.
+//
+// Server is safe for concurrent use.
+type Server struct {
+	httpServer *httptest.Server
+	siteCode   string
+
+	mx                  sync.Mutex
+	configurations      map[string][]byte // by environment
+	lastModified        string
+	revision            int64
+	remoteData          map[string][]byte
+	remoteVisitorData   map[string][]byte
+	trackingLines       []string
+	configurationHits   int
+	accessTokenRequests int
+	sseSubscribers      map[chan string]struct{}
+}
+
+// NewServer starts a server serving the site code. It must be closed with Close.
+func NewServer(siteCode string) *Server {
+	s := &Server{
+		siteCode:          siteCode,
+		configurations:    make(map[string][]byte),
+		remoteData:        make(map[string][]byte),
+		remoteVisitorData: make(map[string][]byte),
+		sseSubscribers:    make(map[chan string]struct{}),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v3/", s.handleConfiguration)
+	mux.HandleFunc("/sse", s.handleSse)
+	mux.HandleFunc("/oauth/token", s.handleAccessToken)
+	mux.HandleFunc("/visit/events", s.handleTracking)
+	mux.HandleFunc("/visit/visitor", s.handleVisitorData)
+	mux.HandleFunc("/map/map", s.handleRemoteData)
+	s.httpServer = httptest.NewServer(mux)
+	return s
+}
+
+// URL returns the base URL of the server.
+func (s *Server) URL() string {
+	return s.httpServer.URL
+}
+
+// Close closes the open SSE streams and shuts the server down.
+func (s *Server) Close() {
+	s.mx.Lock()
+	for ch := range s.sseSubscribers {
+		close(ch)
+		delete(s.sseSubscribers, ch)
+	}
+	s.mx.Unlock()
+	s.httpServer.Close()
+}
+
+// SetConfiguration sets the raw configuration served for the default environment.
+func (s *Server) SetConfiguration(rawConfiguration []byte) {
+	s.SetEnvironmentConfiguration("", rawConfiguration)
+}
+
+// SetEnvironmentConfiguration sets the raw configuration served for the environment.
+// Every call refreshes the Last-Modified value, so the clients fetch the new configuration.
+func (s *Server) SetEnvironmentConfiguration(environment string, rawConfiguration []byte) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.configurations[environment] = rawConfiguration
+	// Each revision is a second later, so consecutive updates never share a Last-Modified value
+	s.revision++
+	s.lastModified = time.Now().Add(time.Duration(s.revision) * time.Second).UTC().Format(http.TimeFormat)
+}
+
+// PushConfigurationUpdate sends a configuration-update-event with the timestamp to all the SSE subscribers.
+func (s *Server) PushConfigurationUpdate(ts int64) {
+	data := fmt.Sprintf(`{"ts":%d}`, ts)
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	for ch := range s.sseSubscribers {
+		select {
+		case ch <- data:
+		default:
+		}
+	}
+}
+
+// SseSubscriberCount returns the number of open SSE streams.
+func (s *Server) SseSubscriberCount() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return len(s.sseSubscribers)
+}
+
+// SetRemoteData sets the raw JSON served by the remote data endpoint for the key.
+func (s *Server) SetRemoteData(key string, rawData []byte) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.remoteData[key] = rawData
+}
+
+// SetRemoteVisitorData sets the raw JSON served by the visitor data endpoint for the visitor code
+// (or mapping value).
+func (s *Server) SetRemoteVisitorData(visitorCode string, rawData []byte) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.remoteVisitorData[visitorCode] = rawData
+}
+
+// TrackingLines returns all the tracking lines posted so far.
+func (s *Server) TrackingLines() []string {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return append([]string(nil), s.trackingLines...)
+}
+
+// ResetTrackingLines forgets the tracking lines posted so far.
+func (s *Server) ResetTrackingLines() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.trackingLines = nil
+}
+
+// ConfigurationHits returns the number of configuration requests.
+func (s *Server) ConfigurationHits() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.configurationHits
+}
+
+// AccessTokenRequests returns the number of access token requests.
+func (s *Server) AccessTokenRequests() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.accessTokenRequests
+}
+
+func (s *Server) handleConfiguration(w http.ResponseWriter, r *http.Request) {
+	if strings.TrimPrefix(r.URL.Path, "/v3/") != s.siteCode {
+		http.NotFound(w, r)
+		return
+	}
+	s.mx.Lock()
+	s.configurationHits++
+	configuration, ok := s.configurations[r.URL.Query().Get("environment")]
+	if !ok {
+		configuration, ok = s.configurations[""]
+	}
+	lastModified := s.lastModified
+	s.mx.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if (lastModified != "") && (r.Header.Get("If-Modified-Since") == lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if lastModified != "" {
+		w.Header().Set("Last-Modified", lastModified)
+	}
+	w.Write(configuration)
+}
+
+func (s *Server) handleSse(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	ch := make(chan string, 16)
+	s.mx.Lock()
+	s.sseSubscribers[ch] = struct{}{}
+	s.mx.Unlock()
+	defer func() {
+		s.mx.Lock()
+		delete(s.sseSubscribers, ch)
+		s.mx.Unlock()
+	}()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case data, ok := <-ch:
+			if !ok {
+				return
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", configurationUpdateEvent, data)
+			flusher.Flush()
+		}
+	}
+}
+
+func (s *Server) handleAccessToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	s.mx.Lock()
+	s.accessTokenRequests++
+	s.mx.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"access_token":"%s","expires_in":%d}`, defaultAccessToken, accessTokenLifetime)
+}
+
+func (s *Server) handleTracking(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := readTrackingBody(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mx.Lock()
+	for _, line := range strings.Split(string(body), "\n") {
+		if line != "" {
+			s.trackingLines = append(s.trackingLines, line)
+		}
+	}
+	s.mx.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// readTrackingBody reads the tracking request body decoding it according to Content-Encoding.
+func readTrackingBody(r *http.Request) ([]byte, error) {
+	switch encoding := r.Header.Get("Content-Encoding"); encoding {
+	case "":
+		return io.ReadAll(r.Body)
+	case "gzip":
+		gr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		return io.ReadAll(gr)
+	case "zstd":
+		zr, err := zstd.NewReader(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		return io.ReadAll(zr)
+	default:
+		return nil, fmt.Errorf("unsupported content encoding '%s'", encoding)
+	}
+}
+
+func (s *Server) handleVisitorData(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	visitorCode := query.Get("visitorCode")
+	if visitorCode == "" {
+		visitorCode = query.Get("mappingValue")
+	}
+	s.mx.Lock()
+	data, ok := s.remoteVisitorData[visitorCode]
+	s.mx.Unlock()
+	if !ok {
+		data = []byte("{}")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+func (s *Server) handleRemoteData(w http.ResponseWriter, r *http.Request) {
+	s.mx.Lock()
+	data, ok := s.remoteData[r.URL.Query().Get("key")]
+	s.mx.Unlock()
+	if !ok {
+		data = []byte("null")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
@@ -0,0 +1,61 @@
+package kameleoontest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Kameleoon/client-go/v3/managers/tracking"
+)
+
+var _ tracking.TrackingSink = (*TrackingRecorder)(nil)
+
+// TrackingRecorder is a TrackingSink which keeps the tracking events in memory,
+// e.g. to assert the tracked exposures of a real client in tests.
+//
+// TrackingRecorder is safe for concurrent use.
+type TrackingRecorder struct {
+	mx     sync.Mutex
+	events []tracking.TrackingEvent
+}
+
+func NewTrackingRecorder() *TrackingRecorder {
+	return &TrackingRecorder{}
+}
+
+func (r *TrackingRecorder) Write(ctx context.Context, events []tracking.TrackingEvent) error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.events = append(r.events, events...)
+	return nil
+}
+
+// Events returns the recorded events of the given types, all the events if no type is given.
+func (r *TrackingRecorder) Events(eventTypes ...tracking.TrackingEventType) []tracking.TrackingEvent {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	var events []tracking.TrackingEvent
+	for _, event := range r.events {
+		if matchesEventType(event, eventTypes) {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func (r *TrackingRecorder) Reset() {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.events = nil
+}
+
+func matchesEventType(event tracking.TrackingEvent, eventTypes []tracking.TrackingEventType) bool {
+	if len(eventTypes) == 0 {
+		return true
+	}
+	for _, eventType := range eventTypes {
+		if event.Type == eventType {
+			return true
+		}
+	}
+	return false
+}
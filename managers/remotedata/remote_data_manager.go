@@ -1,6 +1,7 @@
 package remotedata
 
 import (
+	"context"
 	"encoding/json"
 	"time"
 
@@ -12,9 +13,9 @@ import (
 )
 
 type RemoteDataManager interface {
-	GetData(key string, timeout ...time.Duration) ([]byte, error)
+	GetData(ctx context.Context, key string, timeout ...time.Duration) ([]byte, error)
 	GetVisitorData(
-		visitorCode string, filter types.RemoteVisitorDataFilter, addData bool, timeout ...time.Duration,
+		ctx context.Context, visitorCode string, filter types.RemoteVisitorDataFilter, addData bool, timeout ...time.Duration,
 	) ([]types.Data, error)
 }
 
@@ -39,13 +40,15 @@ func NewRemoteDataManager(
 	return remoteDataManagerImpl
 }
 
-func (rdm *remoteDataManagerImpl) GetData(key string, timeout ...time.Duration) ([]byte, error) {
+func (rdm *remoteDataManagerImpl) GetData(
+	ctx context.Context, key string, timeout ...time.Duration,
+) ([]byte, error) {
 	logging.Debug("CALL: remoteDataManagerImpl.GetData(key: %s, timeout: %s)", key, timeout)
 	timeoutValue := time.Duration(-1)
 	if len(timeout) > 0 {
 		timeoutValue = timeout[0]
 	}
-	out, err := rdm.networkManager.GetRemoteData(key, timeoutValue)
+	out, err := rdm.networkManager.GetRemoteData(ctx, key, timeoutValue)
 	if err != nil {
 		logging.Error("Failed to fetch remote data for %s: %s", key, err)
 		out = nil
@@ -57,6 +60,7 @@ func (rdm *remoteDataManagerImpl) GetData(key string, timeout ...time.Duration)
 }
 
 func (rdm *remoteDataManagerImpl) GetVisitorData(
+	ctx context.Context,
 	visitorCode string,
 	filter types.RemoteVisitorDataFilter,
 	addData bool,
@@ -79,7 +83,9 @@ func (rdm *remoteDataManagerImpl) GetVisitorData(
 		isUniqueIdentifier = visitor.IsUniqueIdentifier()
 	}
 	filter.ApplyDefaultValues()
-	out, err := rdm.networkManager.GetRemoteVisitorData(visitorCode, filter, isUniqueIdentifier, timeoutValue)
+	out, err := rdm.networkManager.GetRemoteVisitorData(
+		ctx, visitorCode, filter, isUniqueIdentifier, timeoutValue,
+	)
 	if err != nil {
 		logging.Error("Failed to fetch remote visitor data for %s: %s", visitorCode, err)
 		logging.Debug(
@@ -101,11 +107,13 @@ func (rdm *remoteDataManagerImpl) GetVisitorData(
 		// Cannot use `visitorManager.AddData` because it could use remote visitor data for mapping
 		visitor = rdm.visitorManager.GetOrCreateVisitor(visitorCode)
 		visitor.AddBaseData(false, data.CollectDataToAdd()...)
+		rdm.visitorManager.Persist(visitorCode, visitor)
 	}
 	if (filter.VisitorCode == true) && (data.visitorCode != "") {
 		// We apply visitor code from the latest visit fetched from Data API
 		visitor = rdm.visitorManager.GetOrCreateVisitor(visitorCode)
 		visitor.SetMappingIdentifier(&data.visitorCode)
+		rdm.visitorManager.Persist(visitorCode, visitor)
 	}
 	visitorData := data.CollectVisitorDataToReturn()
 	logging.Debug(
@@ -0,0 +1,90 @@
+package tracking
+
+import (
+	"io"
+
+	"github.com/Kameleoon/client-go/v3/logging"
+	"github.com/Kameleoon/client-go/v3/network"
+)
+
+const (
+	// compressedFlushInterval is the amount of the lines after which the compressor is flushed to learn
+	// the compressed size. The more often it is flushed, the worse the compression is.
+	compressedFlushInterval = 64 * 1024
+	// compressedTrailerSize is an upper bound of the bytes written on close
+	compressedTrailerSize = 64
+)
+
+// requestSizeMeter measures the size of the tracking request body made of the added lines.
+type requestSizeMeter interface {
+	add(line string)
+	size() int
+	close()
+}
+
+func newRequestSizeMeter(compression network.Compression) requestSizeMeter {
+	if compression == network.CompressionNone {
+		return &plainSizeMeter{}
+	}
+	m := &compressedSizeMeter{compression: compression}
+	w, err := compression.NewWriter(&m.counter)
+	if err != nil {
+		logging.Error("Failed to measure the compressed tracking request size, the plain size is used: %s", err)
+		return &plainSizeMeter{}
+	}
+	m.writer = w
+	return m
+}
+
+type plainSizeMeter struct {
+	total int
+}
+
+func (m *plainSizeMeter) add(line string) {
+	m.total += len(line)
+}
+
+func (m *plainSizeMeter) size() int {
+	return m.total
+}
+
+func (m *plainSizeMeter) close() {}
+
+// compressedSizeMeter compresses the lines the same way as the request body. The size is the compressed
+// size of the flushed lines plus the plain size of the pending ones, so it never underestimates the body.
+type compressedSizeMeter struct {
+	compression network.Compression
+	counter     byteCounter
+	writer      network.CompressingWriter
+	pending     int
+}
+
+func (m *compressedSizeMeter) add(line string) {
+	io.WriteString(m.writer, line)
+	io.WriteString(m.writer, LinesDelimiter)
+	if m.pending += len(line) + len(LinesDelimiter); m.pending >= compressedFlushInterval {
+		m.writer.Flush()
+		m.pending = 0
+	}
+}
+
+func (m *compressedSizeMeter) size() int {
+	return m.counter.count + m.pending + compressedTrailerSize
+}
+
+func (m *compressedSizeMeter) close() {
+	// The writer is released to the pool, so it must not be touched afterwards
+	if (m.writer != nil) && (m.writer.Close() == nil) {
+		m.compression.ReleaseWriter(m.writer)
+		m.writer = nil
+	}
+}
+
+type byteCounter struct {
+	count int
+}
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	c.count += len(p)
+	return len(p), nil
+}
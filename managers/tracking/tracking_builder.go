@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/Kameleoon/client-go/v3/logging"
+	"github.com/Kameleoon/client-go/v3/network"
 	"github.com/Kameleoon/client-go/v3/storage"
 	"github.com/Kameleoon/client-go/v3/types"
 	"github.com/Kameleoon/client-go/v3/utils"
@@ -16,7 +17,7 @@ type TrackingBuilder struct {
 	dataFile         types.IDataFile
 	visitorManager   storage.VisitorManager
 	requestSizeLimit int
-	totalSize        int
+	sizeMeter        requestSizeMeter
 
 	// Result
 	visitorCodesToSend []string
@@ -28,12 +29,24 @@ type TrackingBuilder struct {
 func NewTrackingBuilder(
 	visitorCodes VisitorCodeCollection, dataFile types.IDataFile, visitorManager storage.VisitorManager,
 	requestSizeLimit int,
+) *TrackingBuilder {
+	return NewTrackingBuilderWithCompression(
+		visitorCodes, dataFile, visitorManager, requestSizeLimit, network.CompressionNone,
+	)
+}
+
+// NewTrackingBuilderWithCompression creates a builder which limits the size of the request body compressed
+// with the compression, so more visitors fit in one request.
+func NewTrackingBuilderWithCompression(
+	visitorCodes VisitorCodeCollection, dataFile types.IDataFile, visitorManager storage.VisitorManager,
+	requestSizeLimit int, compression network.Compression,
 ) *TrackingBuilder {
 	return &TrackingBuilder{
 		visitorCodes:     visitorCodes,
 		dataFile:         dataFile,
 		visitorManager:   visitorManager,
 		requestSizeLimit: requestSizeLimit,
+		sizeMeter:        newRequestSizeMeter(compression),
 	}
 }
 
@@ -66,7 +79,7 @@ func (tb *TrackingBuilder) Build() {
 		return
 	}
 	tb.visitorCodes.Range(func(visitorCode string) bool {
-		if tb.totalSize <= tb.requestSizeLimit {
+		if tb.sizeMeter.size() <= tb.requestSizeLimit {
 			visitor := tb.visitorManager.GetVisitor(visitorCode)
 			isConsentGiven := tb.isConsentGiven(visitor)
 			data := tb.collectTrackingData(visitorCode, visitor, isConsentGiven)
@@ -82,6 +95,7 @@ func (tb *TrackingBuilder) Build() {
 		}
 		return true
 	})
+	tb.sizeMeter.close()
 	tb.built = true
 }
 
@@ -172,7 +186,7 @@ func (tb *TrackingBuilder) collectTrackingLines(
 		if line != "" {
 			line = addLineParams(line, visitorCodeParam, userAgent)
 			tb.trackingLines = append(tb.trackingLines, line)
-			tb.totalSize += len(line)
+			tb.sizeMeter.add(line)
 			userAgent = ""
 		}
 	}
@@ -1,9 +1,13 @@
 package tracking
 
 import (
+	"context"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/Kameleoon/client-go/v3/errs"
 	"github.com/Kameleoon/client-go/v3/logging"
 
 	"github.com/Kameleoon/client-go/v3/managers/data"
@@ -18,21 +22,53 @@ type TrackingManager interface {
 	TrackAll()
 	TrackVisitor(visitorCode string)
 
+	// Drain stops the periodic tracking, tracks all the pending data and waits for the outstanding
+	// requests until the context is done, the outstanding requests are aborted then.
+	// It returns the number of the data which were not delivered.
+	Drain(ctx context.Context) (dropped int, err error)
+
 	Close()
 }
 
 const (
 	LinesDelimiter   = "\n"
-	RequestSizeLimit = 2560 * 1024 // 2.5 * 1024^2 characters
+	RequestSizeLimit = 2560 * 1024 // 2.5 * 1024^2 characters, measured after compression if it is enabled
+
+	drainPollInterval = 10 * time.Millisecond
+	drainMaxRounds    = 3
+
+	// maxRetryBatchesInMemory is the amount of the failed batches kept in memory, the rest is read back
+	// from the queue when it is their turn
+	maxRetryBatchesInMemory = 16
+	minRetryBackoff         = 5 * time.Second
+	maxRetryBackoff         = 5 * time.Minute
 )
 
 type TrackingManagerImpl struct {
+	// Accessed atomically, must be first for alignment
+	inFlightRequests int64
+	inFlightData     int64 // unsent data of the outstanding requests which are not queued
+
 	trackingVisitors VisitorTrackingRegistry
 	dataManager      data.DataManager
 	networkManager   network.NetworkManager
 	visitorManager   storage.VisitorManager
+	queue            TrackingQueue
+	sink             TrackingSink
+	sinkOnly         bool
+	compression      network.Compression // the content encoding of the requests, the size limit is measured with it
+	sinkMx           sync.Mutex
+	sinkPending      map[string]struct{} // nonces of the events written to the sink and not delivered yet
+	retryMx          sync.Mutex
+	retryBatches     []QueuedBatch
+	retryOverflow    []uint64 // ids of the batches to retry which are kept in the queue only
+	retryBackoff     time.Duration
+	retryNotBefore   time.Time
 	trackingTicker   *time.Ticker
 	stopChan         chan struct{}
+	// sendCtx is the context of the periodic requests, it is cancelled once the drain deadline is exceeded
+	sendCtx     context.Context
+	cancelSends context.CancelFunc
 }
 
 func NewTrackingManagerImpl(
@@ -41,8 +77,52 @@ func NewTrackingManagerImpl(
 	visitorManager storage.VisitorManager,
 	trackInterval time.Duration,
 ) *TrackingManagerImpl {
-	logging.Debug("CALL: NewTrackingManagerImpl(dataManager, networkManager, visitorManager, scheduledExecutor, "+
-		"trackInterval: %s)", trackInterval)
+	return NewTrackingManagerImplWithQueue(dataManager, networkManager, visitorManager, trackInterval, nil)
+}
+
+// NewTrackingManagerImplWithQueue creates a tracking manager which writes tracking requests ahead to the queue.
+// The batches left in the queue by the previous run are replayed. The queue may be nil.
+func NewTrackingManagerImplWithQueue(
+	dataManager data.DataManager,
+	networkManager network.NetworkManager,
+	visitorManager storage.VisitorManager,
+	trackInterval time.Duration,
+	queue TrackingQueue,
+) *TrackingManagerImpl {
+	return NewTrackingManagerImplWithSink(dataManager, networkManager, visitorManager, trackInterval, queue, nil, false)
+}
+
+// NewTrackingManagerImplWithSink creates a tracking manager which writes the tracking events to the sink
+// as well. If sinkOnly is set, the events are written to the sink instead of being sent to the Data API,
+// the sink errors are handled the same way as the failed requests then. Otherwise the sink receives
+// a copy of the events and its errors are only logged. The sink may be nil.
+func NewTrackingManagerImplWithSink(
+	dataManager data.DataManager,
+	networkManager network.NetworkManager,
+	visitorManager storage.VisitorManager,
+	trackInterval time.Duration,
+	queue TrackingQueue,
+	sink TrackingSink,
+	sinkOnly bool,
+) *TrackingManagerImpl {
+	return NewTrackingManagerImplWithCompression(dataManager, networkManager, visitorManager, trackInterval,
+		queue, sink, sinkOnly, network.CompressionNone)
+}
+
+// NewTrackingManagerImplWithCompression creates a tracking manager which limits the size of the requests
+// compressed with the compression. It must be the compression the network manager sends the requests with.
+func NewTrackingManagerImplWithCompression(
+	dataManager data.DataManager,
+	networkManager network.NetworkManager,
+	visitorManager storage.VisitorManager,
+	trackInterval time.Duration,
+	queue TrackingQueue,
+	sink TrackingSink,
+	sinkOnly bool,
+	compression network.Compression,
+) *TrackingManagerImpl {
+	logging.Debug("CALL: NewTrackingManagerImplWithCompression(dataManager, networkManager, visitorManager, "+
+		"trackInterval: %s, queue, sink, sinkOnly: %s, compression: %s)", trackInterval, sinkOnly, compression)
 	tm := &TrackingManagerImpl{
 		trackingVisitors: NewRwmxCMapVisitorTrackingRegistry(
 			visitorManager, DefaultStorageLimit, DefaultExtractionLimit,
@@ -50,9 +130,20 @@ func NewTrackingManagerImpl(
 		dataManager:    dataManager,
 		networkManager: networkManager,
 		visitorManager: visitorManager,
+		queue:          queue,
+		sink:           sink,
+		sinkOnly:       sinkOnly && (sink != nil),
+		compression:    compression,
+		sinkPending:    make(map[string]struct{}),
 		trackingTicker: time.NewTicker(trackInterval),
 		stopChan:       make(chan struct{}, 8),
 	}
+	tm.sendCtx, tm.cancelSends = context.WithCancel(context.Background())
+	if queue != nil {
+		for _, batch := range queue.Pending() {
+			tm.addRetryBatch(batch)
+		}
+	}
 	go func() {
 		for {
 			select {
@@ -63,18 +154,83 @@ func NewTrackingManagerImpl(
 			}
 		}
 	}()
-	logging.Debug("RETURN: NewTrackingManagerImpl(dataManager, networkManager, visitorManager, scheduledExecutor, "+
-		"trackInterval: %s) -> (TrackingManagerImpl)", trackInterval)
+	logging.Debug("RETURN: NewTrackingManagerImplWithCompression(dataManager, networkManager, visitorManager, "+
+		"trackInterval: %s, queue, sink, sinkOnly: %s, compression: %s) -> (TrackingManagerImpl)",
+		trackInterval, sinkOnly, compression)
 	return tm
 }
 
 func (tm *TrackingManagerImpl) Close() {
 	logging.Debug("CALL: TrackingManagerImpl.Close()")
+	tm.stopTicker()
+	if tm.queue != nil {
+		if err := tm.queue.Close(); err != nil {
+			logging.Error("Failed to close the tracking queue: %s", err)
+		}
+	}
+	logging.Debug("RETURN: TrackingManagerImpl.Close()")
+}
+
+func (tm *TrackingManagerImpl) stopTicker() {
 	tm.trackingTicker.Stop()
 	if len(tm.stopChan) == 0 {
 		tm.stopChan <- struct{}{}
 	}
-	logging.Debug("RETURN: TrackingManagerImpl.Close()")
+}
+
+func (tm *TrackingManagerImpl) Drain(ctx context.Context) (dropped int, err error) {
+	logging.Debug("CALL: TrackingManagerImpl.Drain()")
+	tm.stopTicker()
+	// Failed requests return their visitors to the registry, so they get a few more attempts
+	for round := 0; (round < drainMaxRounds) && (err == nil); round++ {
+		tm.retryQueuedBatches(ctx, true)
+		for (tm.trackingVisitors.Count() > 0) && (ctx.Err() == nil) {
+			tm.track(ctx, tm.trackingVisitors.Extract())
+		}
+		err = tm.waitInFlightRequests(ctx)
+		if tm.trackingVisitors.Count() == 0 {
+			break
+		}
+	}
+	if err != nil {
+		// The requests started before the drain are aborted as well, their data is counted as dropped
+		tm.cancelSends()
+	}
+	dropped = int(atomic.LoadInt64(&tm.inFlightData)) + tm.countUnsentData(tm.trackingVisitors.Extract())
+	logging.Debug("RETURN: TrackingManagerImpl.Drain() -> (dropped: %s, error: %s)", dropped, err)
+	return dropped, err
+}
+
+func (tm *TrackingManagerImpl) waitInFlightRequests(ctx context.Context) error {
+	if atomic.LoadInt64(&tm.inFlightRequests) == 0 {
+		return nil
+	}
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for atomic.LoadInt64(&tm.inFlightRequests) > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+func (tm *TrackingManagerImpl) countUnsentData(visitorCodes VisitorCodeCollection) int {
+	count := 0
+	visitorCodes.Range(func(visitorCode string) bool {
+		if visitor := tm.visitorManager.GetVisitor(visitorCode); visitor != nil {
+			visitor.EnumerateSendableData(func(s types.Sendable) bool {
+				if s.Unsent() {
+					count++
+				}
+				return true
+			})
+		}
+		return true
+	})
+	return count
 }
 
 func (tm *TrackingManagerImpl) AddVisitorCode(visitorCode string) {
@@ -85,18 +241,20 @@ func (tm *TrackingManagerImpl) AddVisitorCode(visitorCode string) {
 
 func (tm *TrackingManagerImpl) TrackAll() {
 	logging.Debug("CALL: TrackingManagerImpl.TrackAll()")
-	tm.track(tm.trackingVisitors.Extract())
+	tm.retryQueuedBatches(tm.sendCtx, false)
+	tm.track(tm.sendCtx, tm.trackingVisitors.Extract())
 	logging.Debug("RETURN: TrackingManagerImpl.TrackAll()")
 }
 
 func (tm *TrackingManagerImpl) TrackVisitor(visitorCode string) {
 	logging.Debug("CALL: TrackingManagerImpl.TrackVisitor(visitorCode: %s)", visitorCode)
-	tm.track(SingletonVisitorCodeCollection{visitorCode: visitorCode})
+	tm.track(tm.sendCtx, SingletonVisitorCodeCollection{visitorCode: visitorCode})
 	logging.Debug("RETURN: TrackingManagerImpl.TrackVisitor(visitorCode: %s)", visitorCode)
 }
 
-func (tm *TrackingManagerImpl) track(visitorCodes VisitorCodeCollection) {
-	builder := NewTrackingBuilder(visitorCodes, tm.dataManager.DataFile(), tm.visitorManager, RequestSizeLimit)
+func (tm *TrackingManagerImpl) track(ctx context.Context, visitorCodes VisitorCodeCollection) {
+	builder := NewTrackingBuilderWithCompression(visitorCodes, tm.dataManager.DataFile(), tm.visitorManager,
+		RequestSizeLimit, tm.compression)
 	builder.Build()
 	if len(builder.VisitorCodesToKeep()) > 0 {
 		logging.Warning(
@@ -106,11 +264,13 @@ func (tm *TrackingManagerImpl) track(visitorCodes VisitorCodeCollection) {
 		)
 		tm.trackingVisitors.AddAll(builder.VisitorCodesToKeep())
 	}
-	tm.performTrackingRequest(builder.VisitorCodesToSend(), builder.UnsentVisitorData(), builder.TrackingLines())
+	tm.performTrackingRequest(
+		ctx, builder.VisitorCodesToSend(), builder.UnsentVisitorData(), builder.TrackingLines(),
+	)
 }
 
 func (tm *TrackingManagerImpl) performTrackingRequest(
-	visitorCodes []string, unsentVisitorData []types.Sendable, trackingLines []string,
+	ctx context.Context, visitorCodes []string, unsentVisitorData []types.Sendable, trackingLines []string,
 ) {
 	if len(trackingLines) == 0 {
 		return
@@ -120,20 +280,229 @@ func (tm *TrackingManagerImpl) performTrackingRequest(
 		s.MarkAsTransmitting()
 	}
 	lines := strings.Join(trackingLines, LinesDelimiter)
+	batch, queued := tm.enqueue(lines)
+	atomic.AddInt64(&tm.inFlightRequests, 1)
+	if !queued {
+		atomic.AddInt64(&tm.inFlightData, int64(len(unsentVisitorData)))
+	}
 	go func() {
-		out, err := tm.networkManager.SendTrackingData(lines)
+		defer func() {
+			if !queued {
+				atomic.AddInt64(&tm.inFlightData, -int64(len(unsentVisitorData)))
+			}
+			atomic.AddInt64(&tm.inFlightRequests, -1)
+		}()
+		out, err := tm.sendTrackingData(ctx, lines)
 		if (err == nil) && out {
 			logging.Info("Successful request for tracking visitors: %s, data: %s", visitorCodes, unsentVisitorData)
 			for _, s := range unsentVisitorData {
 				s.MarkAsSent()
 			}
+			tm.persistVisitors(visitorCodes)
+			if queued {
+				tm.ack(batch.Id)
+			}
+		} else if queued {
+			// The queue owns the lines from now on, so the data must not be tracked once again
+			logTrackingFailure("Tracking request failed", err)
+			logging.Info("Failed request for tracking visitors: %s, data: %s. The request is kept in the queue",
+				visitorCodes, unsentVisitorData)
+			for _, s := range unsentVisitorData {
+				s.MarkAsSent()
+			}
+			tm.persistVisitors(visitorCodes)
+			tm.addRetryBatch(batch)
 		} else {
-			logging.Error("Tracking request failed: %s", err)
+			logTrackingFailure("Tracking request failed", err)
 			logging.Info("Failed request for tracking visitors: %s, data: %s", visitorCodes, unsentVisitorData)
 			for _, s := range unsentVisitorData {
 				s.MarkAsUnsent()
 			}
+			tm.persistVisitors(visitorCodes)
 			tm.trackingVisitors.AddAll(visitorCodes)
 		}
 	}()
 }
+
+// persistVisitors writes back the visitors whose data changed the sent state.
+func (tm *TrackingManagerImpl) persistVisitors(visitorCodes []string) {
+	for _, visitorCode := range visitorCodes {
+		if visitor := tm.visitorManager.GetVisitor(visitorCode); visitor != nil {
+			tm.visitorManager.Persist(visitorCode, visitor)
+		}
+	}
+}
+
+// logTrackingFailure does not report the requests rejected by the open circuit breaker as errors,
+// the data is kept and sent once the endpoint recovers.
+func logTrackingFailure(msg string, err error) {
+	if _, open := err.(*errs.CircuitOpen); open {
+		logging.Warning("%s: %s. The data is kept until the endpoint recovers", msg, err)
+		return
+	}
+	logging.Error("%s: %s", msg, err)
+}
+
+// sendTrackingData delivers the lines to the sink and (unless the sink replaces it) to the Data API.
+// Along with the Data API, the sink receives every event once: the events of the retried requests
+// are not written again unless the sink failed to receive them.
+func (tm *TrackingManagerImpl) sendTrackingData(ctx context.Context, lines string) (bool, error) {
+	if tm.sink == nil {
+		return tm.networkManager.SendTrackingData(ctx, lines)
+	}
+	events := parseTrackingEvents(lines)
+	if tm.sinkOnly {
+		err := tm.writeToSink(events)
+		return err == nil, err
+	}
+	if newEvents := tm.reserveSinkEvents(events); len(newEvents) > 0 {
+		if err := tm.writeToSink(newEvents); err != nil {
+			logging.Error("Failed to write tracking events to the sink: %s", err)
+			tm.releaseSinkEvents(newEvents)
+		}
+	}
+	out, err := tm.networkManager.SendTrackingData(ctx, lines)
+	if (err == nil) && out {
+		// The delivered events are never sent again, so they are not remembered any longer
+		tm.releaseSinkEvents(events)
+	}
+	return out, err
+}
+
+func (tm *TrackingManagerImpl) writeToSink(events []TrackingEvent) error {
+	ctx, cancel := context.WithTimeout(context.Background(), tm.networkManager.GetDefaultTimeout())
+	defer cancel()
+	return tm.sink.Write(ctx, events)
+}
+
+// reserveSinkEvents returns the events which the sink has not received yet and marks them as received.
+func (tm *TrackingManagerImpl) reserveSinkEvents(events []TrackingEvent) []TrackingEvent {
+	tm.sinkMx.Lock()
+	defer tm.sinkMx.Unlock()
+	newEvents := make([]TrackingEvent, 0, len(events))
+	for _, event := range events {
+		if len(event.Nonce) > 0 {
+			if _, written := tm.sinkPending[event.Nonce]; written {
+				continue
+			}
+			tm.sinkPending[event.Nonce] = struct{}{}
+		}
+		newEvents = append(newEvents, event)
+	}
+	return newEvents
+}
+
+func (tm *TrackingManagerImpl) releaseSinkEvents(events []TrackingEvent) {
+	tm.sinkMx.Lock()
+	defer tm.sinkMx.Unlock()
+	for _, event := range events {
+		delete(tm.sinkPending, event.Nonce)
+	}
+}
+
+func (tm *TrackingManagerImpl) enqueue(lines string) (QueuedBatch, bool) {
+	if tm.queue == nil {
+		return QueuedBatch{}, false
+	}
+	id, err := tm.queue.Append(lines)
+	if err != nil {
+		logging.Error("Failed to write a tracking request to the queue: %s", err)
+		return QueuedBatch{}, false
+	}
+	return QueuedBatch{Id: id, Lines: lines}, true
+}
+
+func (tm *TrackingManagerImpl) ack(id uint64) {
+	if err := tm.queue.Ack(id); err != nil {
+		logging.Error("Failed to acknowledge a tracking request in the queue: %s", err)
+	}
+}
+
+func (tm *TrackingManagerImpl) addRetryBatch(batch QueuedBatch) {
+	tm.retryMx.Lock()
+	defer tm.retryMx.Unlock()
+	if len(tm.retryBatches) < maxRetryBatchesInMemory {
+		tm.retryBatches = append(tm.retryBatches, batch)
+	} else if _, ok := tm.queue.(TrackingQueueReader); ok {
+		tm.retryOverflow = append(tm.retryOverflow, batch.Id)
+	} else {
+		logging.Warning("Too many tracking requests to retry, the request is kept in the queue " +
+			"and is going to be replayed on the next start")
+	}
+}
+
+// retryQueuedBatches resends the queued batches which failed or were left by the previous run.
+// The rounds are spaced out with an exponential backoff while they fail, unless force is set.
+func (tm *TrackingManagerImpl) retryQueuedBatches(ctx context.Context, force bool) {
+	tm.retryMx.Lock()
+	if !force && time.Now().Before(tm.retryNotBefore) {
+		tm.retryMx.Unlock()
+		return
+	}
+	batches := tm.retryBatches
+	tm.retryBatches = nil
+	overflowCount := maxRetryBatchesInMemory - len(batches)
+	if overflowCount > len(tm.retryOverflow) {
+		overflowCount = len(tm.retryOverflow)
+	}
+	overflow := tm.retryOverflow[:overflowCount:overflowCount]
+	tm.retryOverflow = tm.retryOverflow[overflowCount:]
+	tm.retryMx.Unlock()
+	if (len(batches) == 0) && (len(overflow) == 0) {
+		return
+	}
+	atomic.AddInt64(&tm.inFlightRequests, 1)
+	go func() {
+		defer atomic.AddInt64(&tm.inFlightRequests, -1)
+		batches = append(batches, tm.readOverflowBatches(overflow)...)
+		for i, batch := range batches {
+			out, err := tm.sendTrackingData(ctx, batch.Lines)
+			if (err != nil) || !out {
+				logTrackingFailure("Queued tracking request failed", err)
+				// The rest is kept as well, it is likely to fail the same way
+				for _, b := range batches[i:] {
+					tm.addRetryBatch(b)
+				}
+				tm.backOffRetries()
+				return
+			}
+			tm.ack(batch.Id)
+		}
+		tm.resetRetryBackoff()
+	}()
+}
+
+func (tm *TrackingManagerImpl) readOverflowBatches(ids []uint64) []QueuedBatch {
+	reader, ok := tm.queue.(TrackingQueueReader)
+	if !ok {
+		return nil
+	}
+	batches := make([]QueuedBatch, 0, len(ids))
+	for _, id := range ids {
+		lines, err := reader.Read(id)
+		if err != nil {
+			logging.Error("Failed to read a tracking request from the queue: %s", err)
+			continue
+		}
+		batches = append(batches, QueuedBatch{Id: id, Lines: lines})
+	}
+	return batches
+}
+
+func (tm *TrackingManagerImpl) backOffRetries() {
+	tm.retryMx.Lock()
+	defer tm.retryMx.Unlock()
+	if tm.retryBackoff *= 2; tm.retryBackoff < minRetryBackoff {
+		tm.retryBackoff = minRetryBackoff
+	} else if tm.retryBackoff > maxRetryBackoff {
+		tm.retryBackoff = maxRetryBackoff
+	}
+	tm.retryNotBefore = time.Now().Add(tm.retryBackoff)
+}
+
+func (tm *TrackingManagerImpl) resetRetryBackoff() {
+	tm.retryMx.Lock()
+	defer tm.retryMx.Unlock()
+	tm.retryBackoff = 0
+	tm.retryNotBefore = time.Time{}
+}
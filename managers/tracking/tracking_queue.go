@@ -0,0 +1,372 @@
+package tracking
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Kameleoon/client-go/v3/logging"
+)
+
+// TrackingQueue is a write-ahead queue of tracking requests.
+// Tracking lines are appended before they are sent and acknowledged after they are delivered,
+// so the batches which are not acknowledged can be replayed after a restart.
+type TrackingQueue interface {
+	// Append durably stores the tracking lines and returns the batch identifier.
+	Append(lines string) (uint64, error)
+	// Ack marks the batch as delivered.
+	Ack(id uint64) error
+	// Pending returns the batches which were left unacknowledged by the previous run.
+	Pending() []QueuedBatch
+	Close() error
+}
+
+// TrackingQueueReader is implemented by the queues which can read a pending batch back, so the tracking manager
+// keeps only a bounded amount of the batches to retry in memory.
+type TrackingQueueReader interface {
+	// Read returns the lines of the batch which is not acknowledged yet.
+	Read(id uint64) (string, error)
+}
+
+type QueuedBatch struct {
+	Id    uint64
+	Lines string
+}
+
+const (
+	trackingQueueSegmentPrefix = "tracking_"
+	trackingQueueSegmentSuffix = ".wal"
+	trackingQueueSegmentSize   = 4 * 1024 * 1024
+
+	trackingQueueRecordBatch byte = 'B'
+	trackingQueueRecordAck   byte = 'A'
+	// kind (1) + id (8) + payload length (4) + CRC-32 of the payload (4)
+	trackingQueueRecordHeaderSize = 17
+)
+
+// FileTrackingQueue is a TrackingQueue stored as append-only segment files in a directory.
+//
+// A batch and its acknowledgement are always written to the same segment, so a segment is removed
+// as soon as all its batches are acknowledged. A torn record at the end of a segment (e.g. after a crash
+// in the middle of a write) is detected with its checksum and ignored with everything after it.
+//
+// Append syncs the segment before it returns, so a batch is durable once it is sent. It costs an fsync
+// per tracking request, the acknowledgements are not synced.
+//
+// The directory must not be shared between several clients.
+type FileTrackingQueue struct {
+	mx       sync.Mutex
+	dir      string
+	nextId   uint64
+	nextSeq  uint64
+	current  *trackingQueueSegment
+	segments map[uint64]*trackingQueueSegment // by batch id
+	pending  []QueuedBatch
+	closed   bool
+}
+
+type trackingQueueSegment struct {
+	path    string
+	file    *os.File
+	size    int64
+	pending map[uint64]int64 // offsets of the records of the pending batches
+}
+
+// NewFileTrackingQueue opens the queue in the directory, creating the directory if needed,
+// and loads the batches left unacknowledged by the previous run.
+func NewFileTrackingQueue(dir string) (*FileTrackingQueue, error) {
+	logging.Debug("CALL: NewFileTrackingQueue(dir: %s)", dir)
+	q := &FileTrackingQueue{
+		dir:      dir,
+		nextId:   1,
+		segments: make(map[uint64]*trackingQueueSegment),
+	}
+	err := q.load()
+	if err != nil {
+		q = nil
+	}
+	logging.Debug("RETURN: NewFileTrackingQueue(dir: %s) -> (queue, error: %s)", dir, err)
+	return q, err
+}
+
+func (q *FileTrackingQueue) load() error {
+	if err := os.MkdirAll(q.dir, 0o755); err != nil {
+		return err
+	}
+	seqs, err := q.listSegments()
+	if err != nil {
+		return err
+	}
+	for _, seq := range seqs {
+		segment := &trackingQueueSegment{path: q.segmentPath(seq), pending: make(map[uint64]int64)}
+		batches, offsets, maxId, err := readTrackingQueueSegment(segment.path)
+		if err != nil {
+			logging.Error("Failed to read tracking queue segment %s: %s", segment.path, err)
+		}
+		if maxId >= q.nextId {
+			q.nextId = maxId + 1
+		}
+		if len(batches) == 0 {
+			os.Remove(segment.path)
+			continue
+		}
+		for i, batch := range batches {
+			segment.pending[batch.Id] = offsets[i]
+			q.segments[batch.Id] = segment
+		}
+		q.pending = append(q.pending, batches...)
+	}
+	if len(seqs) > 0 {
+		q.nextSeq = seqs[len(seqs)-1] + 1
+	}
+	if len(q.pending) > 0 {
+		logging.Info("%s tracking requests left from the previous run are going to be replayed", len(q.pending))
+	}
+	return nil
+}
+
+func (q *FileTrackingQueue) listSegments() ([]uint64, error) {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return nil, err
+	}
+	var seqs []uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, trackingQueueSegmentPrefix) ||
+			!strings.HasSuffix(name, trackingQueueSegmentSuffix) {
+			continue
+		}
+		seqStr := strings.TrimSuffix(strings.TrimPrefix(name, trackingQueueSegmentPrefix), trackingQueueSegmentSuffix)
+		if seq, err := strconv.ParseUint(seqStr, 10, 64); err == nil {
+			seqs = append(seqs, seq)
+		}
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs, nil
+}
+
+func (q *FileTrackingQueue) segmentPath(seq uint64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%s%020d%s", trackingQueueSegmentPrefix, seq, trackingQueueSegmentSuffix))
+}
+
+func (q *FileTrackingQueue) Pending() []QueuedBatch {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	return append([]QueuedBatch(nil), q.pending...)
+}
+
+func (q *FileTrackingQueue) Append(lines string) (id uint64, err error) {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	if q.closed {
+		return 0, errors.New("tracking queue is closed")
+	}
+	if (q.current == nil) || (q.current.size >= trackingQueueSegmentSize) {
+		if err = q.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	id = q.nextId
+	offset := q.current.size
+	if err = q.current.write(trackingQueueRecordBatch, id, []byte(lines)); err == nil {
+		err = q.current.file.Sync()
+	}
+	if err != nil {
+		return 0, err
+	}
+	q.nextId++
+	q.current.pending[id] = offset
+	q.segments[id] = q.current
+	return id, nil
+}
+
+// Ack is accepted after Close as well, so the requests which were outstanding on close are not replayed.
+func (q *FileTrackingQueue) Ack(id uint64) error {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	segment, ok := q.segments[id]
+	if !ok {
+		return nil
+	}
+	delete(q.segments, id)
+	delete(segment.pending, id)
+	for i, batch := range q.pending {
+		if batch.Id == id {
+			q.pending = append(q.pending[:i], q.pending[i+1:]...)
+			break
+		}
+	}
+	if (len(segment.pending) == 0) && ((segment != q.current) || q.closed) {
+		segment.close()
+		return os.Remove(segment.path)
+	}
+	// A lost acknowledgement only causes a duplicate, which is discarded by its nonce, so no sync here
+	if segment.file == nil {
+		file, err := os.OpenFile(segment.path, os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		return writeTrackingQueueRecord(file, trackingQueueRecordAck, id, nil)
+	}
+	return segment.write(trackingQueueRecordAck, id, nil)
+}
+
+// Read reads the record of the batch at its offset, so the segment is not parsed once again.
+func (q *FileTrackingQueue) Read(id uint64) (string, error) {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	// The lock keeps the segment from being removed by Ack meanwhile
+	segment, ok := q.segments[id]
+	if !ok {
+		return "", fmt.Errorf("tracking queue batch %d is not pending", id)
+	}
+	file, err := os.Open(segment.path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	offset := segment.pending[id]
+	header := make([]byte, trackingQueueRecordHeaderSize)
+	if _, err = file.ReadAt(header, offset); err != nil {
+		return "", err
+	}
+	if (header[0] != trackingQueueRecordBatch) || (binary.BigEndian.Uint64(header[1:9]) != id) {
+		return "", fmt.Errorf("tracking queue batch %d is not found at %d in %s", id, offset, segment.path)
+	}
+	payload := make([]byte, binary.BigEndian.Uint32(header[9:13]))
+	if _, err = file.ReadAt(payload, offset+trackingQueueRecordHeaderSize); err != nil {
+		return "", err
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[13:17]) {
+		return "", fmt.Errorf("tracking queue batch %d checksum mismatch in %s", id, segment.path)
+	}
+	return string(payload), nil
+}
+
+func (q *FileTrackingQueue) rotate() error {
+	if q.current != nil {
+		q.current.close()
+		if len(q.current.pending) == 0 {
+			os.Remove(q.current.path)
+		}
+	}
+	path := q.segmentPath(q.nextSeq)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	q.nextSeq++
+	q.current = &trackingQueueSegment{path: path, file: file, pending: make(map[uint64]int64)}
+	return nil
+}
+
+func (q *FileTrackingQueue) Close() error {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	if q.closed {
+		return nil
+	}
+	q.closed = true
+	if q.current != nil {
+		q.current.close()
+		if len(q.current.pending) == 0 {
+			return os.Remove(q.current.path)
+		}
+	}
+	return nil
+}
+
+func (s *trackingQueueSegment) write(kind byte, id uint64, payload []byte) error {
+	if err := writeTrackingQueueRecord(s.file, kind, id, payload); err != nil {
+		return err
+	}
+	s.size += int64(trackingQueueRecordHeaderSize + len(payload))
+	return nil
+}
+
+func (s *trackingQueueSegment) close() {
+	if s.file != nil {
+		s.file.Close()
+		s.file = nil
+	}
+}
+
+func writeTrackingQueueRecord(w io.Writer, kind byte, id uint64, payload []byte) error {
+	record := make([]byte, trackingQueueRecordHeaderSize+len(payload))
+	record[0] = kind
+	binary.BigEndian.PutUint64(record[1:9], id)
+	binary.BigEndian.PutUint32(record[9:13], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[13:17], crc32.ChecksumIEEE(payload))
+	copy(record[trackingQueueRecordHeaderSize:], payload)
+	// A single write, so a record is either appended as a whole or torn at the end of the file
+	_, err := w.Write(record)
+	return err
+}
+
+// readTrackingQueueSegment returns the unacknowledged batches of the segment in order along with the offsets
+// of their records, and the greatest batch id.
+func readTrackingQueueSegment(path string) ([]QueuedBatch, []int64, uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	var batches []QueuedBatch
+	var offsets []int64
+	acked := make(map[uint64]struct{})
+	var maxId uint64
+	var offset int64
+	header := make([]byte, trackingQueueRecordHeaderSize)
+	for {
+		if _, err = io.ReadFull(reader, header); err != nil {
+			break
+		}
+		id := binary.BigEndian.Uint64(header[1:9])
+		payload := make([]byte, binary.BigEndian.Uint32(header[9:13]))
+		if _, err = io.ReadFull(reader, payload); err != nil {
+			break
+		}
+		if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[13:17]) {
+			err = errors.New("tracking queue record checksum mismatch")
+			break
+		}
+		if id > maxId {
+			maxId = id
+		}
+		switch header[0] {
+		case trackingQueueRecordBatch:
+			batches = append(batches, QueuedBatch{Id: id, Lines: string(payload)})
+			offsets = append(offsets, offset)
+		case trackingQueueRecordAck:
+			acked[id] = struct{}{}
+		}
+		offset += int64(trackingQueueRecordHeaderSize + len(payload))
+	}
+	if errors.Is(err, io.EOF) {
+		err = nil
+	} else if errors.Is(err, io.ErrUnexpectedEOF) {
+		logging.Warning("Tracking queue segment %s ends with an incomplete record, which is ignored", path)
+		err = nil
+	}
+	unacked := batches[:0]
+	unackedOffsets := offsets[:0]
+	for i, batch := range batches {
+		if _, ok := acked[batch.Id]; !ok {
+			unacked = append(unacked, batch)
+			unackedOffsets = append(unackedOffsets, offsets[i])
+		}
+	}
+	return unacked, unackedOffsets, maxId, err
+}
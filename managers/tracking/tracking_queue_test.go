@@ -0,0 +1,179 @@
+package tracking
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func openTestTrackingQueue(t *testing.T, dir string) *FileTrackingQueue {
+	t.Helper()
+	q, err := NewFileTrackingQueue(dir)
+	if err != nil {
+		t.Fatalf("NewFileTrackingQueue: %v", err)
+	}
+	return q
+}
+
+func appendTestBatch(t *testing.T, q *FileTrackingQueue, lines string) uint64 {
+	t.Helper()
+	id, err := q.Append(lines)
+	if err != nil {
+		t.Fatalf("Append(%q): %v", lines, err)
+	}
+	return id
+}
+
+func listTestSegments(t *testing.T, dir string) []string {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join(dir, trackingQueueSegmentPrefix+"*"+trackingQueueSegmentSuffix))
+	if err != nil {
+		t.Fatalf("Glob: %v", err)
+	}
+	return paths
+}
+
+func TestFileTrackingQueue_ReplaysUnackedBatchesAfterReopen(t *testing.T) {
+	dir := t.TempDir()
+	q := openTestTrackingQueue(t, dir)
+	id1 := appendTestBatch(t, q, "line1")
+	id2 := appendTestBatch(t, q, "line2")
+	id3 := appendTestBatch(t, q, "line3")
+	if err := q.Ack(id2); err != nil {
+		t.Fatalf("Ack: %v", err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	q = openTestTrackingQueue(t, dir)
+	defer q.Close()
+	pending := q.Pending()
+	expected := []QueuedBatch{{Id: id1, Lines: "line1"}, {Id: id3, Lines: "line3"}}
+	if len(pending) != len(expected) {
+		t.Fatalf("Pending() = %v, expected %v", pending, expected)
+	}
+	for i := range expected {
+		if pending[i] != expected[i] {
+			t.Fatalf("Pending() = %v, expected %v", pending, expected)
+		}
+	}
+	for _, batch := range expected {
+		if lines, err := q.Read(batch.Id); (err != nil) || (lines != batch.Lines) {
+			t.Fatalf("Read(%d) = (%q, %v), expected %q", batch.Id, lines, err, batch.Lines)
+		}
+	}
+	if id := appendTestBatch(t, q, "line4"); id <= id3 {
+		t.Fatalf("Append after reopen returned id %d, expected it greater than %d", id, id3)
+	}
+}
+
+func TestFileTrackingQueue_IgnoresTornTail(t *testing.T) {
+	dir := t.TempDir()
+	q := openTestTrackingQueue(t, dir)
+	id1 := appendTestBatch(t, q, "line1")
+	appendTestBatch(t, q, "line2")
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	segments := listTestSegments(t, dir)
+	if len(segments) != 1 {
+		t.Fatalf("segments = %v, expected one", segments)
+	}
+	info, err := os.Stat(segments[0])
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	// The last record loses its last bytes as if the process crashed in the middle of the write
+	if err = os.Truncate(segments[0], info.Size()-2); err != nil {
+		t.Fatalf("Truncate: %v", err)
+	}
+
+	q = openTestTrackingQueue(t, dir)
+	pending := q.Pending()
+	if (len(pending) != 1) || (pending[0] != QueuedBatch{Id: id1, Lines: "line1"}) {
+		t.Fatalf("Pending() = %v, expected only batch %d", pending, id1)
+	}
+	q.Close()
+}
+
+func TestFileTrackingQueue_IgnoresCorruptTail(t *testing.T) {
+	dir := t.TempDir()
+	q := openTestTrackingQueue(t, dir)
+	id1 := appendTestBatch(t, q, "line1")
+	appendTestBatch(t, q, "line2")
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	segments := listTestSegments(t, dir)
+	if len(segments) != 1 {
+		t.Fatalf("segments = %v, expected one", segments)
+	}
+	content, err := os.ReadFile(segments[0])
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	// The payload of the last record no longer matches its checksum
+	content[len(content)-1] ^= 0xff
+	if err = os.WriteFile(segments[0], content, 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	q = openTestTrackingQueue(t, dir)
+	pending := q.Pending()
+	if (len(pending) != 1) || (pending[0] != QueuedBatch{Id: id1, Lines: "line1"}) {
+		t.Fatalf("Pending() = %v, expected only batch %d", pending, id1)
+	}
+	q.Close()
+}
+
+func TestFileTrackingQueue_RemovesFullyAckedSegments(t *testing.T) {
+	dir := t.TempDir()
+	q := openTestTrackingQueue(t, dir)
+	id1 := appendTestBatch(t, q, "line1")
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// The reopened queue appends to a new segment, so the old one is complete
+	q = openTestTrackingQueue(t, dir)
+	defer q.Close()
+	id2 := appendTestBatch(t, q, "line2")
+	if segments := listTestSegments(t, dir); len(segments) != 2 {
+		t.Fatalf("segments = %v, expected two", segments)
+	}
+	if err := q.Ack(id1); err != nil {
+		t.Fatalf("Ack: %v", err)
+	}
+	if segments := listTestSegments(t, dir); len(segments) != 1 {
+		t.Fatalf("segments = %v, expected the acknowledged one to be removed", segments)
+	}
+	if _, err := q.Read(id1); err == nil {
+		t.Fatalf("Read(%d) of the acknowledged batch succeeded", id1)
+	}
+	if lines, err := q.Read(id2); (err != nil) || (lines != "line2") {
+		t.Fatalf("Read(%d) = (%q, %v), expected %q", id2, lines, err, "line2")
+	}
+}
+
+func TestFileTrackingQueue_AcceptsAckAfterClose(t *testing.T) {
+	dir := t.TempDir()
+	q := openTestTrackingQueue(t, dir)
+	id1 := appendTestBatch(t, q, "line1")
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	// The request outstanding on close is delivered afterwards
+	if err := q.Ack(id1); err != nil {
+		t.Fatalf("Ack after Close: %v", err)
+	}
+	if segments := listTestSegments(t, dir); len(segments) != 0 {
+		t.Fatalf("segments = %v, expected none", segments)
+	}
+
+	q = openTestTrackingQueue(t, dir)
+	defer q.Close()
+	if pending := q.Pending(); len(pending) != 0 {
+		t.Fatalf("Pending() = %v, expected none", pending)
+	}
+}
@@ -0,0 +1,82 @@
+package tracking
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/Kameleoon/client-go/v3/utils"
+)
+
+type TrackingEventType string
+
+const (
+	TrackingEventActivity         TrackingEventType = "activity"
+	TrackingEventConversion       TrackingEventType = "conversion"
+	TrackingEventCustomData       TrackingEventType = "customData"
+	TrackingEventExperiment       TrackingEventType = "experiment"
+	TrackingEventGeolocation      TrackingEventType = "geolocation"
+	TrackingEventPageView         TrackingEventType = "page"
+	TrackingEventStaticData       TrackingEventType = "staticData"
+	TrackingEventTargetingSegment TrackingEventType = "targetingSegment"
+)
+
+// TrackingEvent is a single tracking line. VisitorCode is the mapping value if the line is tracked by it.
+type TrackingEvent struct {
+	VisitorCode string
+	Type        TrackingEventType
+	// Nonce is unique per event, it allows deduplicating the events repeated after a restart
+	Nonce string
+	// Line is the query string sent to the Data API
+	Line string
+}
+
+// TrackingSink receives the tracking events, e.g. to copy them to a message broker or a file.
+// Write is called from the tracking goroutines, so it must be safe for concurrent use.
+type TrackingSink interface {
+	Write(ctx context.Context, events []TrackingEvent) error
+}
+
+func parseTrackingEvents(lines string) []TrackingEvent {
+	split := strings.Split(lines, LinesDelimiter)
+	events := make([]TrackingEvent, 0, len(split))
+	for _, line := range split {
+		if len(line) == 0 {
+			continue
+		}
+		event := TrackingEvent{Line: line}
+		if params, err := url.ParseQuery(line); err == nil {
+			event.Type = TrackingEventType(params.Get(utils.QPEventType))
+			event.Nonce = params.Get(utils.QPNonce)
+			if event.VisitorCode = params.Get(utils.QPVisitorCode); len(event.VisitorCode) == 0 {
+				event.VisitorCode = params.Get(utils.QPMappingValue)
+			}
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// WriterTrackingSink writes the tracking lines to the writer, one line per event.
+type WriterTrackingSink struct {
+	mx     sync.Mutex
+	writer io.Writer
+}
+
+func NewWriterTrackingSink(writer io.Writer) *WriterTrackingSink {
+	return &WriterTrackingSink{writer: writer}
+}
+
+func (s *WriterTrackingSink) Write(ctx context.Context, events []TrackingEvent) error {
+	var sb strings.Builder
+	for _, event := range events {
+		sb.WriteString(event.Line)
+		sb.WriteString(LinesDelimiter)
+	}
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	_, err := io.WriteString(s.writer, sb.String())
+	return err
+}
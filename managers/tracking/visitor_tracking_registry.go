@@ -11,6 +11,7 @@ type VisitorTrackingRegistry interface {
 	Add(visitorCode string)
 	AddAll(visitorCodes []string)
 	Extract() VisitorCodeCollection
+	Count() int
 }
 
 // Rwmx VisitorTrackingRegistry with ConcurrentMap
@@ -87,6 +88,12 @@ func (vtr *RwmxCMapVisitorTrackingRegistry) eraseToStorageLimit() {
 	}
 }
 
+func (vtr *RwmxCMapVisitorTrackingRegistry) Count() int {
+	vtr.mutex.RLock()
+	defer vtr.mutex.RUnlock()
+	return vtr.visitors.Count()
+}
+
 func (vtr *RwmxCMapVisitorTrackingRegistry) Extract() VisitorCodeCollection {
 	if vtr.shouldExtractAllBeUsed() {
 		return vtr.extractAll(true)
@@ -1,6 +1,7 @@
 package warehouse
 
 import (
+	"context"
 	"github.com/Kameleoon/client-go/v3/logging"
 	"encoding/json"
 	"time"
@@ -13,7 +14,8 @@ import (
 
 type WarehouseManager interface {
 	GetVisitorWarehouseAudience(
-		visitorCode string, warehouseKey string, customDataIndex int, timeout time.Duration) (*types.CustomData, error)
+		ctx context.Context, visitorCode string, warehouseKey string, customDataIndex int, timeout time.Duration,
+	) (*types.CustomData, error)
 }
 
 type warehouseResponse struct {
@@ -37,7 +39,8 @@ func NewWarehouseManagerImpl(networkManager network.NetworkManager,
 }
 
 func (wm *warehouseManagerImpl) GetVisitorWarehouseAudience(
-	visitorCode string, warehouseKey string, customDataIndex int, timeout time.Duration) (*types.CustomData, error) {
+	ctx context.Context, visitorCode string, warehouseKey string, customDataIndex int, timeout time.Duration,
+) (*types.CustomData, error) {
 	logging.Debug(
 		"CALL: warehouseManagerImpl.GetVisitorWarehouseAudience(visitorCode: %s, warehouseKey: %s, "+
 			"customDataIndex: %s, timeout: %s)", visitorCode, warehouseKey, customDataIndex, timeout)
@@ -52,7 +55,7 @@ func (wm *warehouseManagerImpl) GetVisitorWarehouseAudience(
 
 	remoteDataKey := remoteDataKey(visitorCode, warehouseKey)
 
-	remoteData, err := wm.networkManager.GetRemoteData(remoteDataKey, timeout)
+	remoteData, err := wm.networkManager.GetRemoteData(ctx, remoteDataKey, timeout)
 	if err != nil {
 		logging.Debug(
 			"RETURN: warehouseManagerImpl.GetVisitorWarehouseAudience(visitorCode: %s, warehouseKey: %s, "+
@@ -1,6 +1,7 @@
 package network
 
 import (
+	"context"
 	"encoding/json"
 	"time"
 
@@ -13,7 +14,7 @@ const (
 )
 
 type AccessTokenSource interface {
-	GetToken(timeout time.Duration) string
+	GetToken(ctx context.Context, timeout time.Duration) string
 	DiscardToken(token string)
 }
 
@@ -25,7 +26,7 @@ type AccessTokenSourceImpl struct {
 	fetching       bool
 }
 
-func (ats *AccessTokenSourceImpl) GetToken(timeout time.Duration) string {
+func (ats *AccessTokenSourceImpl) GetToken(ctx context.Context, timeout time.Duration) string {
 	logging.Debug("CALL: AccessTokenSourceImpl.GetToken(timeout: %s)", timeout)
 	now := time.Now()
 	token := ats.cachedToken
@@ -33,11 +34,12 @@ func (ats *AccessTokenSourceImpl) GetToken(timeout time.Duration) string {
 	if token != nil && !token.isExpired(now) {
 		if !ats.fetching && token.isObsolete(now) {
 			ats.fetching = true // set `fetching` here as well to reduce the number of requests until goroutine runned
-			go ats.fetchToken(timeout)
+			// The background refresh must outlive the request which triggered it
+			go ats.fetchToken(context.Background(), timeout)
 		}
 		resultToken = token.value
 	} else {
-		resultToken = ats.fetchToken(timeout)
+		resultToken = ats.fetchToken(ctx, timeout)
 	}
 	logging.Debug("RETURN: AccessTokenSourceImpl.GetToken(timeout: %s) -> (token: %s)", timeout, resultToken)
 	return resultToken
@@ -52,11 +54,11 @@ func (ats *AccessTokenSourceImpl) DiscardToken(token string) {
 	logging.Debug("RETURN: AccessTokenSourceImpl.DiscardToken(token: %s)", token)
 }
 
-func (ats *AccessTokenSourceImpl) fetchToken(timeout time.Duration) string {
+func (ats *AccessTokenSourceImpl) fetchToken(ctx context.Context, timeout time.Duration) string {
 	logging.Debug("CALL: AccessTokenSourceImpl.fetchToken(timeout: %s)", timeout)
 	ats.fetching = true
 	defer func() { ats.fetching = false }()
-	jsonResponse, err := ats.networkManager.FetchAccessJWToken(ats.clientId, ats.clientSecret, timeout)
+	jsonResponse, err := ats.networkManager.FetchAccessJWToken(ctx, ats.clientId, ats.clientSecret, timeout)
 	var token string
 	if err != nil {
 		logging.Error("Failed to read access JWT: %s", err)
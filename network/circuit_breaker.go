@@ -0,0 +1,283 @@
+package network
+
+import (
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/Kameleoon/client-go/v3/errs"
+	"github.com/Kameleoon/client-go/v3/logging"
+)
+
+const (
+	DefaultCircuitFailureRateThreshold  = 0.5
+	DefaultCircuitSlowCallRateThreshold = 0.5
+	DefaultCircuitMinimumCalls          = 10
+	DefaultCircuitWindow                = 30 * time.Second
+	DefaultCircuitOpenDuration          = 30 * time.Second
+	DefaultCircuitHalfOpenProbes        = 1
+)
+
+type CircuitState int
+
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+type CircuitStateChange struct {
+	Host string
+	From CircuitState
+	To   CircuitState
+}
+
+// CircuitBreakerConfig configures the circuit breakers of the Kameleoon hosts.
+//
+// A breaker opens if at least MinimumCalls calls are made within Window and the ratio of the failed calls
+// (network errors, 408, 429 and 5xx) reaches FailureRateThreshold, or the ratio of the calls slower than
+// SlowCallThreshold reaches SlowCallRateThreshold. While open, the requests to the host fail fast with
+// errs.CircuitOpen. After OpenDuration the breaker lets HalfOpenProbes requests through and closes if all of
+// them succeed, otherwise it opens again. Zero values are replaced with the defaults, zero SlowCallThreshold
+// disables the latency check.
+type CircuitBreakerConfig struct {
+	Disabled              bool          `yml:"disabled" yaml:"disabled"`
+	FailureRateThreshold  float64       `yml:"failure_rate_threshold" yaml:"failure_rate_threshold"`
+	SlowCallThreshold     time.Duration `yml:"slow_call_threshold" yaml:"slow_call_threshold"`
+	SlowCallRateThreshold float64       `yml:"slow_call_rate_threshold" yaml:"slow_call_rate_threshold"`
+	MinimumCalls          int           `yml:"minimum_calls" yaml:"minimum_calls"`
+	Window                time.Duration `yml:"window" yaml:"window"`
+	OpenDuration          time.Duration `yml:"open_duration" yaml:"open_duration"`
+	HalfOpenProbes        int           `yml:"half_open_probes" yaml:"half_open_probes"`
+	// OnStateChange is called on every state transition. It is called synchronously, so it must not block.
+	OnStateChange func(change CircuitStateChange) `yml:"-" yaml:"-"`
+}
+
+func (c CircuitBreakerConfig) WithDefaults() CircuitBreakerConfig {
+	if (c.FailureRateThreshold <= 0) || (c.FailureRateThreshold > 1) {
+		c.FailureRateThreshold = DefaultCircuitFailureRateThreshold
+	}
+	if (c.SlowCallRateThreshold <= 0) || (c.SlowCallRateThreshold > 1) {
+		c.SlowCallRateThreshold = DefaultCircuitSlowCallRateThreshold
+	}
+	if c.MinimumCalls <= 0 {
+		c.MinimumCalls = DefaultCircuitMinimumCalls
+	}
+	if c.Window <= 0 {
+		c.Window = DefaultCircuitWindow
+	}
+	if c.OpenDuration <= 0 {
+		c.OpenDuration = DefaultCircuitOpenDuration
+	}
+	if c.HalfOpenProbes <= 0 {
+		c.HalfOpenProbes = DefaultCircuitHalfOpenProbes
+	}
+	return c
+}
+
+// CircuitBreakers keeps a circuit breaker per host. Nil CircuitBreakers let all the requests through.
+type CircuitBreakers struct {
+	config   CircuitBreakerConfig
+	mx       sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+// NewCircuitBreakers returns nil if the circuit breakers are disabled.
+func NewCircuitBreakers(config CircuitBreakerConfig) *CircuitBreakers {
+	if config.Disabled {
+		return nil
+	}
+	return &CircuitBreakers{config: config.WithDefaults(), breakers: make(map[string]*circuitBreaker)}
+}
+
+// State returns the state of the circuit breaker of the host.
+func (cbs *CircuitBreakers) State(host string) CircuitState {
+	if cbs == nil {
+		return CircuitClosed
+	}
+	cb := cbs.breaker(host)
+	cb.mx.Lock()
+	defer cb.mx.Unlock()
+	if (cb.state == CircuitOpen) && (time.Since(cb.openedAt) >= cbs.config.OpenDuration) {
+		return CircuitHalfOpen
+	}
+	return cb.state
+}
+
+func (cbs *CircuitBreakers) breaker(host string) *circuitBreaker {
+	cbs.mx.Lock()
+	defer cbs.mx.Unlock()
+	cb, exists := cbs.breakers[host]
+	if !exists {
+		cb = &circuitBreaker{host: host, config: &cbs.config, windowStart: time.Now()}
+		cbs.breakers[host] = cb
+	}
+	return cb
+}
+
+// acquire returns the permit to call the url, or errs.CircuitOpen if the breaker of its host is open.
+func (cbs *CircuitBreakers) acquire(rawUrl string) (circuitPermit, error) {
+	if cbs == nil {
+		return circuitPermit{}, nil
+	}
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return circuitPermit{}, nil
+	}
+	cb := cbs.breaker(u.Host)
+	permit, change, err := cb.acquire(time.Now())
+	cb.notify(change)
+	return permit, err
+}
+
+type circuitPermit struct {
+	breaker    *circuitBreaker
+	generation uint64
+	probe      bool
+	startedAt  time.Time
+}
+
+// done records the outcome of the call, ignored calls (e.g. cancelled by the caller) only free the probe slot.
+func (p circuitPermit) done(failed bool, ignored bool) {
+	if p.breaker == nil {
+		return
+	}
+	change := p.breaker.record(p, failed, ignored, time.Now())
+	p.breaker.notify(change)
+}
+
+type circuitBreaker struct {
+	host   string
+	config *CircuitBreakerConfig
+
+	mx             sync.Mutex
+	state          CircuitState
+	generation     uint64
+	windowStart    time.Time
+	calls          int
+	failures       int
+	slowCalls      int
+	openedAt       time.Time
+	probes         int
+	probeSuccesses int
+}
+
+func (cb *circuitBreaker) acquire(now time.Time) (circuitPermit, *CircuitStateChange, error) {
+	cb.mx.Lock()
+	defer cb.mx.Unlock()
+	var change *CircuitStateChange
+	if (cb.state == CircuitOpen) && (now.Sub(cb.openedAt) >= cb.config.OpenDuration) {
+		change = cb.transit(CircuitHalfOpen, now)
+	}
+	switch cb.state {
+	case CircuitOpen:
+		return circuitPermit{}, change, errs.NewCircuitOpen(cb.host)
+	case CircuitHalfOpen:
+		if cb.probes+cb.probeSuccesses >= cb.config.HalfOpenProbes {
+			return circuitPermit{}, change, errs.NewCircuitOpen(cb.host)
+		}
+		cb.probes++
+	}
+	permit := circuitPermit{breaker: cb, generation: cb.generation, probe: cb.state == CircuitHalfOpen, startedAt: now}
+	return permit, change, nil
+}
+
+func (cb *circuitBreaker) record(p circuitPermit, failed bool, ignored bool, now time.Time) *CircuitStateChange {
+	cb.mx.Lock()
+	defer cb.mx.Unlock()
+	// The outcomes of the calls started before the last transition do not describe the current state
+	if p.generation != cb.generation {
+		return nil
+	}
+	slow := (cb.config.SlowCallThreshold > 0) && (now.Sub(p.startedAt) > cb.config.SlowCallThreshold)
+	if p.probe {
+		cb.probes--
+		switch {
+		case ignored:
+			return nil
+		case failed || slow:
+			return cb.transit(CircuitOpen, now)
+		}
+		if cb.probeSuccesses++; cb.probeSuccesses >= cb.config.HalfOpenProbes {
+			return cb.transit(CircuitClosed, now)
+		}
+		return nil
+	}
+	if ignored {
+		return nil
+	}
+	if now.Sub(cb.windowStart) >= cb.config.Window {
+		cb.resetWindow(now)
+	}
+	cb.calls++
+	if failed {
+		cb.failures++
+	}
+	if slow {
+		cb.slowCalls++
+	}
+	if cb.calls < cb.config.MinimumCalls {
+		return nil
+	}
+	failureRate := float64(cb.failures) / float64(cb.calls)
+	slowCallRate := float64(cb.slowCalls) / float64(cb.calls)
+	if (failureRate >= cb.config.FailureRateThreshold) || (slowCallRate >= cb.config.SlowCallRateThreshold) {
+		return cb.transit(CircuitOpen, now)
+	}
+	return nil
+}
+
+func (cb *circuitBreaker) transit(state CircuitState, now time.Time) *CircuitStateChange {
+	change := &CircuitStateChange{Host: cb.host, From: cb.state, To: state}
+	cb.state = state
+	cb.generation++
+	cb.probes = 0
+	cb.probeSuccesses = 0
+	if state == CircuitOpen {
+		cb.openedAt = now
+	}
+	cb.resetWindow(now)
+	return change
+}
+
+func (cb *circuitBreaker) resetWindow(now time.Time) {
+	cb.windowStart = now
+	cb.calls = 0
+	cb.failures = 0
+	cb.slowCalls = 0
+}
+
+func (cb *circuitBreaker) notify(change *CircuitStateChange) {
+	if change == nil {
+		return
+	}
+	if change.To == CircuitOpen {
+		logging.Warning("Circuit breaker for %s is open, the requests fail fast for %s",
+			change.Host, cb.config.OpenDuration)
+	} else {
+		logging.Info("Circuit breaker for %s is %s", change.Host, change.To)
+	}
+	if cb.config.OnStateChange != nil {
+		cb.config.OnStateChange(*change)
+	}
+}
+
+// isCircuitFailure reports whether the response says that the host is unhealthy.
+func isCircuitFailure(response Response) bool {
+	if response.Err != nil {
+		return true
+	}
+	return (response.Code == 408) || (response.Code == 429) || (response.Code >= 500)
+}
@@ -0,0 +1,102 @@
+package network
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const HeaderContentEncoding = "Content-Encoding"
+
+// Compression is the content encoding of the tracking request bodies.
+type Compression string
+
+const (
+	CompressionNone Compression = ""
+	CompressionGzip Compression = "gzip"
+	CompressionZstd Compression = "zstd"
+)
+
+func (c Compression) IsValid() bool {
+	return (c == CompressionNone) || (c == CompressionGzip) || (c == CompressionZstd)
+}
+
+// CompressingWriter compresses the data written to it. Flush writes the pending compressed data,
+// Close writes the rest of it.
+type CompressingWriter interface {
+	io.WriteCloser
+	Flush() error
+}
+
+type resettableWriter interface {
+	CompressingWriter
+	Reset(w io.Writer)
+}
+
+// The encoders are expensive to create, a zstd one allocates several megabytes, so they are reused
+var (
+	gzipWriters sync.Pool
+	zstdWriters sync.Pool
+)
+
+func (c Compression) writerPool() *sync.Pool {
+	switch c {
+	case CompressionGzip:
+		return &gzipWriters
+	case CompressionZstd:
+		return &zstdWriters
+	}
+	return nil
+}
+
+// NewWriter returns a writer which compresses the data to w, or nil for CompressionNone.
+// The writer may be returned with ReleaseWriter after it is closed, so it is reused.
+func (c Compression) NewWriter(w io.Writer) (CompressingWriter, error) {
+	if pool := c.writerPool(); pool != nil {
+		if pooled, ok := pool.Get().(resettableWriter); ok {
+			pooled.Reset(w)
+			return pooled, nil
+		}
+	}
+	switch c {
+	case CompressionNone:
+		return nil, nil
+	case CompressionGzip:
+		return gzip.NewWriter(w), nil
+	case CompressionZstd:
+		return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+	}
+	return nil, fmt.Errorf("unsupported compression '%s'", c)
+}
+
+// ReleaseWriter returns the closed writer created with NewWriter for reuse.
+func (c Compression) ReleaseWriter(w CompressingWriter) {
+	if pool := c.writerPool(); (pool != nil) && (w != nil) {
+		w.(resettableWriter).Reset(nil)
+		pool.Put(w)
+	}
+}
+
+// Compress returns the compressed data, the data is returned as is for CompressionNone.
+func (c Compression) Compress(data string) (string, error) {
+	if c == CompressionNone {
+		return data, nil
+	}
+	var buf bytes.Buffer
+	w, err := c.NewWriter(&buf)
+	if err != nil {
+		return "", err
+	}
+	if _, err = io.WriteString(w, data); err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		return "", err
+	}
+	c.ReleaseWriter(w)
+	return buf.String(), nil
+}
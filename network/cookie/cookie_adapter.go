@@ -0,0 +1,148 @@
+package cookie
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Cookie is a transport-agnostic description of a cookie written by the SDK.
+type Cookie struct {
+	Name     string
+	Value    string
+	Path     string
+	Domain   string
+	Expires  time.Time
+	HttpOnly bool
+}
+
+// RequestCookies gives read access to the cookies of an incoming HTTP request.
+type RequestCookies interface {
+	// Cookie returns the value of the named cookie and whether it was present.
+	Cookie(name string) (string, bool)
+}
+
+// ResponseCookies gives access to the cookies of an outgoing HTTP response.
+type ResponseCookies interface {
+	// Cookie returns the value of the named cookie already set on the response and whether it was present.
+	Cookie(name string) (string, bool)
+	// SetCookie sets the cookie on the response.
+	SetCookie(cookie *Cookie)
+}
+
+// fasthttp
+
+type fastHttpRequestCookies struct {
+	request *fasthttp.Request
+}
+
+func NewFastHttpRequestCookies(request *fasthttp.Request) RequestCookies {
+	return fastHttpRequestCookies{request: request}
+}
+
+func (c fastHttpRequestCookies) Cookie(name string) (string, bool) {
+	if value := c.request.Header.Cookie(name); value != nil {
+		return string(value), true
+	}
+	return "", false
+}
+
+type fastHttpResponseCookies struct {
+	response *fasthttp.Response
+}
+
+func NewFastHttpResponseCookies(response *fasthttp.Response) ResponseCookies {
+	return fastHttpResponseCookies{response: response}
+}
+
+func (c fastHttpResponseCookies) Cookie(name string) (string, bool) {
+	ckBin := c.response.Header.PeekCookie(name)
+	if ckBin == nil {
+		return "", false
+	}
+	ck := string(ckBin)
+	token := name + "="
+	start := strings.Index(ck, token)
+	if start == -1 {
+		return "", false
+	}
+	start += len(token)
+	end := start
+	for (end < len(ck)) && (ck[end] != ';') {
+		end++
+	}
+	return ck[start:end], true
+}
+
+func (c fastHttpResponseCookies) SetCookie(cookie *Cookie) {
+	ck := fasthttp.AcquireCookie()
+	defer fasthttp.ReleaseCookie(ck)
+	ck.SetKey(cookie.Name)
+	ck.SetValue(cookie.Value)
+	ck.SetExpire(cookie.Expires)
+	ck.SetHTTPOnly(cookie.HttpOnly)
+	ck.SetPath(cookie.Path)
+	ck.SetDomain(cookie.Domain)
+	c.response.Header.SetCookie(ck)
+}
+
+// net/http
+
+type httpRequestCookies struct {
+	request *http.Request
+}
+
+func NewHttpRequestCookies(request *http.Request) RequestCookies {
+	return httpRequestCookies{request: request}
+}
+
+func (c httpRequestCookies) Cookie(name string) (string, bool) {
+	if c.request == nil {
+		return "", false
+	}
+	ck, err := c.request.Cookie(name)
+	if err != nil {
+		return "", false
+	}
+	return ck.Value, true
+}
+
+type httpResponseCookies struct {
+	writer http.ResponseWriter
+}
+
+func NewHttpResponseCookies(writer http.ResponseWriter) ResponseCookies {
+	return httpResponseCookies{writer: writer}
+}
+
+func (c httpResponseCookies) Cookie(name string) (string, bool) {
+	if c.writer == nil {
+		return "", false
+	}
+	// http.ResponseWriter has no cookie accessor, so the Set-Cookie headers are parsed back.
+	// The last cookie with the name wins as it is the one the browser keeps.
+	header := http.Header{"Set-Cookie": c.writer.Header().Values("Set-Cookie")}
+	value, found := "", false
+	for _, ck := range (&http.Response{Header: header}).Cookies() {
+		if ck.Name == name {
+			value, found = ck.Value, true
+		}
+	}
+	return value, found
+}
+
+func (c httpResponseCookies) SetCookie(cookie *Cookie) {
+	if c.writer == nil {
+		return
+	}
+	http.SetCookie(c.writer, &http.Cookie{
+		Name:     cookie.Name,
+		Value:    cookie.Value,
+		Path:     cookie.Path,
+		Domain:   cookie.Domain,
+		Expires:  cookie.Expires,
+		HttpOnly: cookie.HttpOnly,
+	})
+}
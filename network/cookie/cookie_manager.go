@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"errors"
 	"net/url"
-	"strings"
 	"time"
 
 	"github.com/Kameleoon/client-go/v3/errs"
@@ -14,7 +13,6 @@ import (
 
 	"github.com/Kameleoon/client-go/v3/managers/data"
 	"github.com/Kameleoon/client-go/v3/utils"
-	"github.com/valyala/fasthttp"
 )
 
 const (
@@ -23,36 +21,10 @@ const (
 	cookieTTL              = 380 * 24 * time.Hour
 )
 
-func getVisitorCodeFromResponseCookie(response *fasthttp.Response) string {
-	logging.Debug("CALL: getVisitorCodeFromResponseCookie(response)")
-	const token = visitorCodeCookie + "="
-	ckBin := response.Header.PeekCookie(visitorCodeCookie)
-	var visitorCode string
-	if ckBin == nil {
-		visitorCode = ""
-	} else {
-		ck := string(ckBin)
-		start := strings.Index(ck, token)
-		if start == -1 {
-			visitorCode = ""
-		} else {
-			start += len(token)
-			end := start
-			for (end < len(ck)) && (ck[end] != ';') {
-				end++
-			}
-			visitorCode = ck[start:end]
-		}
-	}
-	logging.Debug("RETURN: getVisitorCodeFromResponseCookie(response) -> (visitorCode: %s)",
-		visitorCode)
-	return visitorCode
-}
-
 type CookieManager interface {
-	GetOrAdd(request *fasthttp.Request, response *fasthttp.Response, defaultVisitorCode ...string) (string, error)
+	GetOrAdd(request RequestCookies, response ResponseCookies, defaultVisitorCode ...string) (string, error)
 
-	Update(visitorCode string, legalConsent bool, response *fasthttp.Response)
+	Update(visitorCode string, legalConsent bool, response ResponseCookies)
 }
 
 type CookieManagerImpl struct {
@@ -76,7 +48,7 @@ func NewCookieManagerImpl(
 	return cookieManagerImpl
 }
 
-func (cm *CookieManagerImpl) Update(visitorCode string, consent bool, response *fasthttp.Response) {
+func (cm *CookieManagerImpl) Update(visitorCode string, consent bool, response ResponseCookies) {
 	logging.Debug("CALL: CookieManagerImpl.Update(visitorCode: %s, consent: %s, response)", visitorCode, consent)
 	if consent {
 		cm.add(visitorCode, response)
@@ -84,7 +56,7 @@ func (cm *CookieManagerImpl) Update(visitorCode string, consent bool, response *
 	logging.Debug("RETURN: CookieManagerImpl.Update(visitorCode: %s, consent: %s, response)", visitorCode, consent)
 }
 
-func (cm *CookieManagerImpl) GetOrAdd(request *fasthttp.Request, response *fasthttp.Response,
+func (cm *CookieManagerImpl) GetOrAdd(request RequestCookies, response ResponseCookies,
 	defaultVisitorCode ...string) (string, error) {
 	logging.Debug("CALL: CookieManagerImpl.GetOrAdd(request, response, defaultVisitorCode: %s)",
 		defaultVisitorCode)
@@ -98,18 +70,18 @@ func (cm *CookieManagerImpl) GetOrAdd(request *fasthttp.Request, response *fasth
 }
 
 func (cm *CookieManagerImpl) getOrAddVisitorCode(
-	request *fasthttp.Request, response *fasthttp.Response, defaultVisitorCode ...string,
+	request RequestCookies, response ResponseCookies, defaultVisitorCode ...string,
 ) (string, error) {
 	var vc string
 
-	if vc = getVisitorCodeFromResponseCookie(response); len(vc) > 0 {
-		logging.Debug("Read visitor code %s from response %s", vc, response)
+	if vc, _ = response.Cookie(visitorCodeCookie); len(vc) > 0 {
+		logging.Debug("Read visitor code %s from response", vc)
 		return vc, nil
 	}
 
-	if binaryVC := request.Header.Cookie(visitorCodeCookie); binaryVC != nil {
-		vc = string(binaryVC)
-		logging.Debug("Read visitor code %s from request %s", vc, request)
+	var found bool
+	if vc, found = request.Cookie(visitorCodeCookie); found {
+		logging.Debug("Read visitor code %s from request", vc)
 	} else {
 		if len(defaultVisitorCode) > 0 {
 			vc = defaultVisitorCode[0]
@@ -133,22 +105,22 @@ func (cm *CookieManagerImpl) getOrAddVisitorCode(
 	return vc, err
 }
 
-func (cm *CookieManagerImpl) add(visitorCode string, response *fasthttp.Response) {
+func (cm *CookieManagerImpl) add(visitorCode string, response ResponseCookies) {
 	logging.Debug("CALL: CookieManagerImpl.add(visitorCode: %s, response)", visitorCode)
-	ck := fasthttp.AcquireCookie()
-	defer fasthttp.ReleaseCookie(ck)
-	ck.SetKey(visitorCodeCookie)
-	ck.SetValue(visitorCode)
-	ck.SetExpire(time.Now().Add(cookieTTL))
-	ck.SetHTTPOnly(false)
-	ck.SetPath("/")
-	ck.SetDomain(cm.topLevelDomain)
-	response.Header.SetCookie(ck)
+	ck := &Cookie{
+		Name:     visitorCodeCookie,
+		Value:    visitorCode,
+		Expires:  time.Now().Add(cookieTTL),
+		HttpOnly: false,
+		Path:     "/",
+		Domain:   cm.topLevelDomain,
+	}
+	response.SetCookie(ck)
 	logging.Debug("For %s was added cookies: %s", visitorCode, ck)
 	logging.Debug("RETURN: CookieManagerImpl.add(visitorCode: %s, response)", visitorCode)
 }
 
-func (cm *CookieManagerImpl) processSimulatedVariations(request *fasthttp.Request, visitorCode string) {
+func (cm *CookieManagerImpl) processSimulatedVariations(request RequestCookies, visitorCode string) {
 	svms, err := readSimulatedVariationsJson(request)
 	if err == nil {
 		var svs []*types.ForcedFeatureVariation
@@ -156,16 +128,17 @@ func (cm *CookieManagerImpl) processSimulatedVariations(request *fasthttp.Reques
 		if err == nil {
 			visitor := cm.visitorManager.GetOrCreateVisitor(visitorCode)
 			visitor.UpdateSimulatedVariations(svs)
+			cm.visitorManager.Persist(visitorCode, visitor)
 			return
 		}
 	}
 	logging.Error("Failed to process simulated variations cookie: %s", err)
 }
 
-func readSimulatedVariationsJson(request *fasthttp.Request) (svms map[string]simulatedVariationModel, err error) {
-	if binarySV := request.Header.Cookie(simulationFFDataCookie); binarySV != nil {
+func readSimulatedVariationsJson(request RequestCookies) (svms map[string]simulatedVariationModel, err error) {
+	if rawSV, found := request.Cookie(simulationFFDataCookie); found {
 		var unescapedSV string
-		if unescapedSV, err = url.QueryUnescape(string(binarySV)); err != nil {
+		if unescapedSV, err = url.QueryUnescape(rawSV); err != nil {
 			return
 		}
 		err = json.Unmarshal([]byte(unescapedSV), &svms)
@@ -0,0 +1,108 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/Kameleoon/client-go/v3/logging"
+)
+
+var ErrTooManyRedirects = errors.New("too many redirects detected when doing the request")
+
+// HttpNetProvider is the NetProvider implementation on net/http.
+//
+// Redirects are handled the same way as by NetProviderImpl: only 307 and 308 are followed (keeping the method,
+// the body and all the headers) up to MaxRedirectsCount times, other redirect responses are returned as is.
+type HttpNetProvider struct {
+	client http.Client
+}
+
+// NewHttpNetProvider creates a net provider with HTTP/2 support. The proxy is taken from the environment
+// (HTTPS_PROXY, HTTP_PROXY, NO_PROXY) unless proxyUrl is set. The server certificates are verified against
+// the system root CAs if tlsConfig is nil.
+func NewHttpNetProvider(maxConnsPerHost int, proxyUrl string, tlsConfig *tls.Config) *HttpNetProvider {
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	transport.MaxConnsPerHost = maxConnsPerHost
+	transport.ForceAttemptHTTP2 = true
+	transport.Proxy = http.ProxyFromEnvironment
+	if len(proxyUrl) > 0 {
+		if u, err := url.Parse(proxyUrl); err == nil {
+			transport.Proxy = http.ProxyURL(u)
+		} else {
+			logging.Error("Invalid proxy URL %s is ignored by the net provider: %s", proxyUrl, err)
+		}
+	}
+	return NewHttpNetProviderWithTransport(transport)
+}
+
+// NewHttpNetProviderWithTransport creates a net provider which makes the requests through the transport,
+// e.g. a RoundTripper chain which adds auth headers, routes the egress traffic or traces the requests.
+func NewHttpNetProviderWithTransport(transport http.RoundTripper) *HttpNetProvider {
+	np := &HttpNetProvider{client: http.Client{Transport: transport}}
+	np.client.CheckRedirect = checkRedirect
+	return np
+}
+
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if (req.Response == nil) || ((req.Response.StatusCode != 307) && (req.Response.StatusCode != 308)) {
+		return http.ErrUseLastResponse
+	}
+	if len(via) > MaxRedirectsCount {
+		return ErrTooManyRedirects
+	}
+	// net/http drops the authorization on redirects to another domain, while fasthttp keeps it
+	if auth := via[0].Header.Get(AuthorizationHeader); len(auth) > 0 {
+		req.Header.Set(AuthorizationHeader, auth)
+	}
+	return nil
+}
+
+func (np *HttpNetProvider) Call(ctx context.Context, request *Request, headersToRead []string) Response {
+	if err := ctx.Err(); err != nil {
+		return Response{Err: err, Request: request}
+	}
+	ctx, cancel := context.WithDeadline(ctx, makeDeadline(ctx, request.Timeout))
+	defer cancel()
+	req, err := http.NewRequestWithContext(
+		ctx, string(request.Method), request.Url, bytes.NewBufferString(request.Data),
+	)
+	if err != nil {
+		return Response{Err: err, Request: request}
+	}
+	np.setHeaders(req, request)
+	resp, err := np.client.Do(req)
+	if err != nil {
+		return Response{Err: err, Request: request}
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Response{Err: err, Request: request}
+	}
+	headersRead := make(map[string]string)
+	for _, h := range headersToRead {
+		headersRead[h] = resp.Header.Get(h)
+	}
+	return Response{Code: resp.StatusCode, Body: body, HeadersRead: headersRead, Request: request}
+}
+
+func (*HttpNetProvider) setHeaders(req *http.Request, request *Request) {
+	for key, value := range request.Headers {
+		req.Header.Set(key, value)
+	}
+	if len(request.AccessToken) > 0 {
+		req.Header.Set(AuthorizationHeader, "Bearer "+request.AccessToken)
+	}
+	if len(request.ContentType) > 0 {
+		req.Header.Set(HeaderContentTypeName, string(request.ContentType))
+	}
+}
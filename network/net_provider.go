@@ -1,6 +1,7 @@
 package network
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"strings"
@@ -43,7 +44,9 @@ type Request struct {
 func (r Request) String() string {
 	body := "nil"
 	if r.Data != "" {
-		if strings.HasPrefix(r.Data, "client_id=") {
+		if encoding, compressed := r.Headers[HeaderContentEncoding]; compressed {
+			body = fmt.Sprintf("<%s, %d bytes>", encoding, len(r.Data))
+		} else if strings.HasPrefix(r.Data, "client_id=") {
 			body = "****"
 		} else {
 			body = r.Data
@@ -73,7 +76,8 @@ func (r Response) String() string {
 // declaration
 
 type NetProvider interface {
-	Call(request *Request, headersToRead []string) Response
+	// Call performs the request, it is aborted by the earliest of the context deadline and the request timeout.
+	Call(ctx context.Context, request *Request, headersToRead []string) Response
 }
 
 // implementation
@@ -89,14 +93,22 @@ type NetProviderImpl struct {
 
 func NewNetProviderImpl(readTimeout time.Duration, writeTimeout time.Duration,
 	maxConnsPerHost int, proxyUrl string) *NetProviderImpl {
+	return NewNetProviderImplWithTLS(readTimeout, writeTimeout, maxConnsPerHost, proxyUrl, nil)
+}
+
+// NewNetProviderImplWithTLS creates a net provider which uses the TLS configuration for HTTPS requests.
+// The server certificates are verified against the system root CAs if the configuration is nil.
+func NewNetProviderImplWithTLS(readTimeout time.Duration, writeTimeout time.Duration,
+	maxConnsPerHost int, proxyUrl string, tlsConfig *tls.Config) *NetProviderImpl {
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
 	np := &NetProviderImpl{
 		client: fasthttp.Client{
 			ReadTimeout:     readTimeout,
 			WriteTimeout:    writeTimeout,
 			MaxConnsPerHost: maxConnsPerHost,
-			TLSConfig: &tls.Config{
-				InsecureSkipVerify: true,
-			},
+			TLSConfig:       tlsConfig,
 		},
 	}
 	if len(proxyUrl) > 0 {
@@ -105,7 +117,30 @@ func NewNetProviderImpl(readTimeout time.Duration, writeTimeout time.Duration,
 	return np
 }
 
-func (np *NetProviderImpl) Call(request *Request, headersToRead []string) Response {
+// Call aborts the request when the context deadline is reached. If the context has no deadline,
+// the call returns as soon as the context is cancelled, but the request may keep running until its timeout.
+func (np *NetProviderImpl) Call(ctx context.Context, request *Request, headersToRead []string) Response {
+	if err := ctx.Err(); err != nil {
+		return Response{Err: err, Request: request}
+	}
+	if _, hasDeadline := ctx.Deadline(); hasDeadline || (ctx.Done() == nil) {
+		// The deadline is passed to fasthttp, so the call returns by the deadline without watching the context
+		return np.call(ctx, request, headersToRead)
+	}
+	// fasthttp is not context-aware, so the call is run aside to be able to abandon it on cancellation.
+	responseChan := make(chan Response, 1)
+	go func() {
+		responseChan <- np.call(ctx, request, headersToRead)
+	}()
+	select {
+	case response := <-responseChan:
+		return response
+	case <-ctx.Done():
+		return Response{Err: ctx.Err(), Request: request}
+	}
+}
+
+func (np *NetProviderImpl) call(ctx context.Context, request *Request, headersToRead []string) Response {
 	req := fasthttp.AcquireRequest()
 	defer fasthttp.ReleaseRequest(req)
 	req.Header.SetMethod(string(request.Method))
@@ -116,18 +151,29 @@ func (np *NetProviderImpl) Call(request *Request, headersToRead []string) Respon
 	}
 	resp := fasthttp.AcquireResponse()
 	defer fasthttp.ReleaseResponse(resp)
-	err := np.doTimeoutRedirects(req, resp, MaxRedirectsCount, request.Timeout)
+	err := np.doTimeoutRedirects(req, resp, MaxRedirectsCount, makeDeadline(ctx, request.Timeout))
 	// err := np.client.DoTimeout(req, resp, request.Timeout)
 	var response Response
 	if err == nil {
 		headersRead := np.readHeaders(resp, headersToRead)
-		response = Response{Code: resp.StatusCode(), Body: resp.Body(), HeadersRead: headersRead, Request: request}
+		// The body must be copied because the response is released back to the pool
+		body := append([]byte(nil), resp.Body()...)
+		response = Response{Code: resp.StatusCode(), Body: body, HeadersRead: headersRead, Request: request}
 	} else {
 		response = Response{Err: err, Request: request}
 	}
 	return response
 }
 
+// makeDeadline returns the earliest of the request timeout and the context deadline.
+func makeDeadline(ctx context.Context, timeout time.Duration) time.Time {
+	deadline := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	return deadline
+}
+
 func (*NetProviderImpl) setHeaders(req *fasthttp.Request, request *Request) {
 	if len(request.Headers) > 0 {
 		for key, value := range request.Headers {
@@ -154,9 +200,8 @@ func (np *NetProviderImpl) doTimeoutRedirects(
 	req *fasthttp.Request,
 	resp *fasthttp.Response,
 	redirectsLeft int,
-	timeout time.Duration,
+	deadline time.Time,
 ) error {
-	deadline := time.Now().Add(timeout)
 	for {
 		if err := np.client.DoDeadline(req, resp, deadline); err != nil {
 			return err
@@ -1,6 +1,7 @@
 package network
 
 import (
+	"context"
 	"encoding/json"
 	"time"
 
@@ -10,10 +11,6 @@ import (
 )
 
 const (
-	networkCallRetriesNumberCritical    = 2
-	NetworkCallAttemptsNumberCritical   = networkCallRetriesNumberCritical + 1 // +1 for initial request
-	NetworkCallAttemptsNumberUncritical = 1
-
 	codeUnauthorized = 401
 )
 
@@ -27,16 +24,18 @@ type NetworkManager interface {
 	GetAccessTokenSource() AccessTokenSource
 
 	// Automation API
-	FetchAccessJWToken(clientId string, clientSecret string, timeout time.Duration) (json.RawMessage, error)
+	FetchAccessJWToken(
+		ctx context.Context, clientId string, clientSecret string, timeout time.Duration,
+	) (json.RawMessage, error)
 
 	// SDK config API
-	FetchConfiguration(ts int64, ifModifiedSince string) (FetchedConfiguration, error)
+	FetchConfiguration(ctx context.Context, ts int64, ifModifiedSince string) (FetchedConfiguration, error)
 
 	// Data API
-	GetRemoteData(key string, timeout time.Duration) (json.RawMessage, error)
-	GetRemoteVisitorData(visitorCode string, filter types.RemoteVisitorDataFilter, isUniqueIdentifier bool,
-		timeout time.Duration) (json.RawMessage, error)
-	SendTrackingData(trackingLines string) (bool, error)
+	GetRemoteData(ctx context.Context, key string, timeout time.Duration) (json.RawMessage, error)
+	GetRemoteVisitorData(ctx context.Context, visitorCode string, filter types.RemoteVisitorDataFilter,
+		isUniqueIdentifier bool, timeout time.Duration) (json.RawMessage, error)
+	SendTrackingData(ctx context.Context, trackingLines string) (bool, error)
 }
 
 type FetchedConfiguration struct {
@@ -47,13 +46,19 @@ type FetchedConfiguration struct {
 // base implementation
 
 type NetworkManagerImpl struct {
-	Environment            string
-	DefaultTimeout         time.Duration
-	NetProvider            NetProvider
-	UrlProvider            UrlProvider
-	Logger                 logging.Logger
+	Environment     string
+	DefaultTimeout  time.Duration
+	NetProvider     NetProvider
+	UrlProvider     UrlProvider
+	Logger          logging.Logger
+	RetryPolicies   RetryPolicies
+	CircuitBreakers *CircuitBreakers
+	// Deprecated: Please use RetryPolicies.Tracking instead. If it is set, it replaces the delays
+	// of the tracking retry policy, a negative value means no delay.
 	TrackingCallRetryDelay time.Duration
-	accessTokenSource      AccessTokenSource
+	// TrackingCompression is the content encoding of the tracking request bodies
+	TrackingCompression Compression
+	accessTokenSource   AccessTokenSource
 }
 
 func NewNetworkManagerImpl(
@@ -64,11 +69,12 @@ func NewNetworkManagerImpl(
 	accessTokenSourceFactory AccessTokenSourceFactory,
 ) *NetworkManagerImpl {
 	nm := &NetworkManagerImpl{
-		Environment:            environment,
-		DefaultTimeout:         defaultTimeout,
-		NetProvider:            netProvider,
-		UrlProvider:            urlProvider,
-		TrackingCallRetryDelay: DefaultTrackingCallRetryDelay,
+		Environment:     environment,
+		DefaultTimeout:  defaultTimeout,
+		NetProvider:     netProvider,
+		UrlProvider:     urlProvider,
+		RetryPolicies:   RetryPolicies{}.WithDefaults(),
+		CircuitBreakers: NewCircuitBreakers(CircuitBreakerConfig{}),
 	}
 	nm.accessTokenSource = accessTokenSourceFactory.create(nm)
 	return nm
@@ -103,29 +109,54 @@ func (nm *NetworkManagerImpl) ensureTimeout(request *Request) {
 }
 
 func (nm *NetworkManagerImpl) makeCall(
-	request *Request, attemptCount int, retryDelay time.Duration, headersToRead ...string,
+	ctx context.Context, request *Request, policy RetryPolicy, headersToRead ...string,
 ) (Response, error) {
-	logging.Debug("Running request %s with retry limit %s, retry delay %s ms", request, attemptCount, retryDelay)
+	logging.Debug("Running request %s with retry policy %+v", request, policy)
 	nm.ensureTimeout(request)
+	headersToRead = append(headersToRead, HeaderRetryAfter)
 	var err error
 	var isTokenRejected bool
 	var response Response
-	for i := 0; i < attemptCount; i++ {
-		logLevel := nm.getLogLevel(i, attemptCount)
-		if (i > 0) && (retryDelay > 0) {
-			time.Sleep(retryDelay)
+	for attempt := 1; ; attempt++ {
+		logLevel := nm.getLogLevel(attempt, policy.MaxAttempts)
+		nm.authorizeIfRequired(ctx, request)
+		if response, err = nm.callThroughBreaker(ctx, request, headersToRead); err != nil {
+			logging.Debug("%s call %s is skipped: %s", request.Method, request.Url, err)
+			return Response{}, err
 		}
-		nm.authorizeIfRequired(request)
-		response = nm.NetProvider.Call(request, headersToRead)
 		if isTokenRejected, err = nm.processErrors(request, &response, logLevel); err == nil {
 			logging.Debug("Fetched response %s for request %s", response, request)
 			return response, nil
 		}
+		if ctx.Err() != nil {
+			// There is no point to retry if the caller has given up
+			return Response{}, err
+		}
+		// A rejected token is discarded, so the request may succeed with a new one
+		if (attempt >= policy.MaxAttempts) || !(isTokenRejected || policy.IsRetryable(response)) {
+			break
+		}
+		delay, ok := policy.Delay(attempt, response)
+		if !ok {
+			logging.Warning("%s call %s is not retried as the server asks to retry after %s",
+				request.Method, request.Url, delay)
+			break
+		}
+		if deadline, hasDeadline := ctx.Deadline(); hasDeadline && (time.Until(deadline) < delay) {
+			logging.Warning("%s call %s is not retried as the retry delay %s exceeds the deadline",
+				request.Method, request.Url, delay)
+			break
+		}
+		if err = sleepContext(ctx, delay); err != nil {
+			return Response{}, err
+		}
 	}
 	if isTokenRejected {
 		logging.Error("Wrong Kameleoon API access token slows down the SDK's requests")
 		request.AccessToken = ""
-		response = nm.NetProvider.Call(request, headersToRead)
+		if response, err = nm.callThroughBreaker(ctx, request, headersToRead); err != nil {
+			return Response{}, err
+		}
 		if _, err = nm.processErrors(request, &response, logging.ERROR); err == nil {
 			logging.Debug("Fetched response %s for request %s", response, request)
 			return response, nil
@@ -134,16 +165,42 @@ func (nm *NetworkManagerImpl) makeCall(
 	return Response{}, err
 }
 
-func (nm *NetworkManagerImpl) getLogLevel(attempt int, attemptCount int) logging.LogLevel {
-	if attempt == attemptCount-1 {
+// callThroughBreaker makes the call unless the circuit breaker of the host is open.
+func (nm *NetworkManagerImpl) callThroughBreaker(
+	ctx context.Context, request *Request, headersToRead []string,
+) (Response, error) {
+	permit, err := nm.CircuitBreakers.acquire(request.Url)
+	if err != nil {
+		return Response{}, err
+	}
+	response := nm.NetProvider.Call(ctx, request, headersToRead)
+	// The calls abandoned by the caller say nothing about the host
+	permit.done(isCircuitFailure(response), ctx.Err() != nil)
+	return response, nil
+}
+
+// sleepContext pauses the current goroutine for the duration or until the context is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (nm *NetworkManagerImpl) getLogLevel(attempt int, maxAttempts int) logging.LogLevel {
+	if attempt >= maxAttempts {
 		return logging.ERROR
 	}
 	return logging.WARNING
 }
 
-func (nm *NetworkManagerImpl) authorizeIfRequired(request *Request) {
+func (nm *NetworkManagerImpl) authorizeIfRequired(ctx context.Context, request *Request) {
 	if request.IsAuthRequired {
-		request.AccessToken = nm.accessTokenSource.GetToken(request.Timeout)
+		request.AccessToken = nm.accessTokenSource.GetToken(ctx, request.Timeout)
 	}
 }
 
@@ -1,6 +1,7 @@
 package network
 
 import (
+	"context"
 	"encoding/json"
 	"time"
 
@@ -12,7 +13,7 @@ const (
 	HeaderContentTypeName = "Content-Type"
 )
 
-func (nm *NetworkManagerImpl) FetchAccessJWToken(clientId string, clientSecret string,
+func (nm *NetworkManagerImpl) FetchAccessJWToken(ctx context.Context, clientId string, clientSecret string,
 	timeout time.Duration) (json.RawMessage, error) {
 
 	url := nm.UrlProvider.MakeAccessTokenUrl()
@@ -24,7 +25,7 @@ func (nm *NetworkManagerImpl) FetchAccessJWToken(clientId string, clientSecret s
 		Data:        data,
 		Timeout:     timeout,
 	}
-	response, err := nm.makeCall(&request, NetworkCallAttemptsNumberUncritical, -1)
+	response, err := nm.makeCall(ctx, &request, nm.RetryPolicies.AccessToken)
 	return response.Body, err
 }
 
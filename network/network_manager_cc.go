@@ -1,5 +1,7 @@
 package network
 
+import "context"
+
 const (
 	HeaderSdkType         = "X-Kameleoon-SDK-Type"
 	HeaderSdkVersion      = "X-Kameleoon-SDK-Version"
@@ -7,7 +9,7 @@ const (
 	HeaderLastModified    = "Last-Modified"
 )
 
-func (nm *NetworkManagerImpl) FetchConfiguration(ts int64, ifModifiedSince string) (FetchedConfiguration, error) {
+func (nm *NetworkManagerImpl) FetchConfiguration(ctx context.Context, ts int64, ifModifiedSince string) (FetchedConfiguration, error) {
 	url := nm.UrlProvider.MakeConfigurationUrl(nm.Environment, ts)
 	request := &Request{
 		Method:      HttpGet,
@@ -21,7 +23,7 @@ func (nm *NetworkManagerImpl) FetchConfiguration(ts int64, ifModifiedSince strin
 	if ifModifiedSince != "" {
 		request.Headers[HeaderIfModifiedSince] = ifModifiedSince
 	}
-	response, err := nm.makeCall(request, NetworkCallAttemptsNumberCritical, -1, HeaderLastModified)
+	response, err := nm.makeCall(ctx, request, nm.RetryPolicies.Configuration, HeaderLastModified)
 	if err != nil {
 		return FetchedConfiguration{}, err
 	}
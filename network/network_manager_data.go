@@ -1,6 +1,7 @@
 package network
 
 import (
+	"context"
 	"encoding/json"
 	"time"
 
@@ -11,7 +12,7 @@ const (
 	DefaultTrackingCallRetryDelay = time.Second * 5
 )
 
-func (nm *NetworkManagerImpl) GetRemoteData(key string, timeout time.Duration) (json.RawMessage, error) {
+func (nm *NetworkManagerImpl) GetRemoteData(ctx context.Context, key string, timeout time.Duration) (json.RawMessage, error) {
 	url := nm.UrlProvider.MakeApiDataGetRequestUrl(key)
 	request := Request{
 		Method:         HttpGet,
@@ -20,12 +21,16 @@ func (nm *NetworkManagerImpl) GetRemoteData(key string, timeout time.Duration) (
 		Timeout:        timeout,
 		IsAuthRequired: true,
 	}
-	response, err := nm.makeCall(&request, NetworkCallAttemptsNumberUncritical, -1)
+	response, err := nm.makeCall(ctx, &request, nm.RetryPolicies.RemoteData)
 	return response.Body, err
 }
 
 func (nm *NetworkManagerImpl) GetRemoteVisitorData(
-	visitorCode string, filter types.RemoteVisitorDataFilter, isUniqueIdentifier bool, timeout time.Duration,
+	ctx context.Context,
+	visitorCode string,
+	filter types.RemoteVisitorDataFilter,
+	isUniqueIdentifier bool,
+	timeout time.Duration,
 ) (json.RawMessage, error) {
 	url := nm.UrlProvider.MakeVisitorDataGetUrl(visitorCode, filter, isUniqueIdentifier)
 	request := Request{
@@ -35,26 +40,44 @@ func (nm *NetworkManagerImpl) GetRemoteVisitorData(
 		Timeout:        timeout,
 		IsAuthRequired: true,
 	}
-	response, err := nm.makeCall(&request, NetworkCallAttemptsNumberUncritical, -1)
+	response, err := nm.makeCall(ctx, &request, nm.RetryPolicies.RemoteData)
 	return response.Body, err
 }
 
-func (nm *NetworkManagerImpl) SendTrackingData(trackingLines string) (bool, error) {
+func (nm *NetworkManagerImpl) SendTrackingData(ctx context.Context, trackingLines string) (bool, error) {
 	if trackingLines == "" {
 		return false, nil
 	}
 	url := nm.UrlProvider.MakeTrackingUrl()
+	body, err := nm.TrackingCompression.Compress(trackingLines)
+	if err != nil {
+		return false, err
+	}
 	request := Request{
 		Method:         HttpPost,
 		Url:            url,
 		ContentType:    WildcardContentType,
-		Data:           trackingLines,
+		Data:           body,
 		Timeout:        nm.DefaultTimeout,
 		IsAuthRequired: true,
 	}
-	_, err := nm.makeCall(&request, NetworkCallAttemptsNumberCritical, nm.TrackingCallRetryDelay)
+	if nm.TrackingCompression != CompressionNone {
+		request.Headers = map[string]string{HeaderContentEncoding: string(nm.TrackingCompression)}
+	}
+	_, err = nm.makeCall(ctx, &request, nm.trackingRetryPolicy())
 	if err != nil {
 		return false, err
 	}
 	return true, nil
 }
+
+func (nm *NetworkManagerImpl) trackingRetryPolicy() RetryPolicy {
+	policy := nm.RetryPolicies.Tracking
+	if delay := nm.TrackingCallRetryDelay; delay != 0 {
+		if delay < 0 {
+			delay = 0
+		}
+		policy.InitialDelay, policy.MaxDelay = delay, delay
+	}
+	return policy
+}
@@ -0,0 +1,112 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Kameleoon/client-go/v3/errs"
+	"github.com/Kameleoon/client-go/v3/logging"
+	"github.com/Kameleoon/client-go/v3/types"
+)
+
+// OfflineNetworkManager is a NetworkManager which never performs any network I/O.
+// Remote calls fail with errs.OfflineMode. Tracking lines are written to the tracking writer
+// or dropped if there is no writer.
+type OfflineNetworkManager struct {
+	environment    string
+	defaultTimeout time.Duration
+	urlProvider    UrlProvider
+	trackingWriter io.Writer
+	trackingMx     sync.Mutex
+}
+
+func NewOfflineNetworkManager(
+	environment string, defaultTimeout time.Duration, urlProvider UrlProvider, trackingWriter io.Writer,
+) *OfflineNetworkManager {
+	nm := &OfflineNetworkManager{
+		environment:    environment,
+		defaultTimeout: defaultTimeout,
+		urlProvider:    urlProvider,
+		trackingWriter: trackingWriter,
+	}
+	logging.Debug("CALL/RETURN: NewOfflineNetworkManager(environment: %s, defaultTimeout: %s, urlProvider, "+
+		"trackingWriter) -> (networkManager)", environment, defaultTimeout)
+	return nm
+}
+
+func (nm *OfflineNetworkManager) GetEnvironment() string {
+	return nm.environment
+}
+
+func (nm *OfflineNetworkManager) GetDefaultTimeout() time.Duration {
+	return nm.defaultTimeout
+}
+
+// GetNetProvider returns nil as there is no transport in offline mode.
+func (nm *OfflineNetworkManager) GetNetProvider() NetProvider {
+	return nil
+}
+
+func (nm *OfflineNetworkManager) GetUrlProvider() UrlProvider {
+	return nm.urlProvider
+}
+
+func (nm *OfflineNetworkManager) GetAccessTokenSource() AccessTokenSource {
+	return offlineAccessTokenSource{}
+}
+
+func (nm *OfflineNetworkManager) FetchAccessJWToken(
+	ctx context.Context, clientId string, clientSecret string, timeout time.Duration,
+) (json.RawMessage, error) {
+	return nil, errs.NewOfflineMode("Access token fetching")
+}
+
+func (nm *OfflineNetworkManager) FetchConfiguration(
+	ctx context.Context, ts int64, ifModifiedSince string,
+) (FetchedConfiguration, error) {
+	return FetchedConfiguration{}, errs.NewOfflineMode("Configuration fetching")
+}
+
+func (nm *OfflineNetworkManager) GetRemoteData(
+	ctx context.Context, key string, timeout time.Duration,
+) (json.RawMessage, error) {
+	return nil, errs.NewOfflineMode("Remote data fetching")
+}
+
+func (nm *OfflineNetworkManager) GetRemoteVisitorData(ctx context.Context, visitorCode string,
+	filter types.RemoteVisitorDataFilter, isUniqueIdentifier bool, timeout time.Duration,
+) (json.RawMessage, error) {
+	return nil, errs.NewOfflineMode("Remote visitor data fetching")
+}
+
+func (nm *OfflineNetworkManager) SendTrackingData(ctx context.Context, trackingLines string) (bool, error) {
+	if trackingLines == "" {
+		return false, nil
+	}
+	if nm.trackingWriter == nil {
+		logging.Debug("Offline mode: dropped tracking lines %s", trackingLines)
+		return true, nil
+	}
+	nm.trackingMx.Lock()
+	defer nm.trackingMx.Unlock()
+	if !strings.HasSuffix(trackingLines, "\n") {
+		trackingLines += "\n"
+	}
+	if _, err := io.WriteString(nm.trackingWriter, trackingLines); err != nil {
+		logging.Error("Offline mode: failed to write tracking lines: %s", err)
+		return false, err
+	}
+	return true, nil
+}
+
+type offlineAccessTokenSource struct{}
+
+func (offlineAccessTokenSource) GetToken(ctx context.Context, timeout time.Duration) string {
+	return ""
+}
+
+func (offlineAccessTokenSource) DiscardToken(token string) {}
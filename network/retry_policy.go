@@ -0,0 +1,159 @@
+package network
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HeaderRetryAfter = "Retry-After"
+
+	DefaultRetryMultiplier    = 2.0
+	DefaultRetryJitter        = 0.2
+	DefaultRetryMaxRetryAfter = time.Minute
+
+	// Deprecated: Please use MaxAttempts of RetryPolicies.Configuration and RetryPolicies.Tracking instead
+	NetworkCallAttemptsNumberCritical = 3
+	// Deprecated: Please use MaxAttempts of RetryPolicies.RemoteData and RetryPolicies.AccessToken instead
+	NetworkCallAttemptsNumberUncritical = 1
+)
+
+// DefaultRetryableStatusCodes are the status codes of the responses which may succeed if the request is repeated.
+var DefaultRetryableStatusCodes = []int{408, 429, 500, 502, 503, 504}
+
+var (
+	DefaultConfigurationRetryPolicy = RetryPolicy{
+		MaxAttempts: NetworkCallAttemptsNumberCritical, InitialDelay: 500 * time.Millisecond, MaxDelay: 5 * time.Second,
+	}
+	DefaultTrackingRetryPolicy = RetryPolicy{
+		MaxAttempts:  NetworkCallAttemptsNumberCritical,
+		InitialDelay: DefaultTrackingCallRetryDelay, MaxDelay: DefaultTrackingCallRetryDelay, Multiplier: 1,
+	}
+	DefaultRemoteDataRetryPolicy  = RetryPolicy{MaxAttempts: NetworkCallAttemptsNumberUncritical}
+	DefaultAccessTokenRetryPolicy = RetryPolicy{MaxAttempts: NetworkCallAttemptsNumberUncritical}
+)
+
+// RetryPolicy defines how a failed request is repeated.
+//
+// A request is repeated up to MaxAttempts times in total if it fails with a network error or with one of
+// RetryableStatusCodes. The delay grows exponentially from InitialDelay up to MaxDelay, and a random part
+// of Jitter ratio is added or subtracted. The delay requested by the Retry-After header is respected,
+// but the request fails without waiting if it is longer than MaxRetryAfter or than the caller's deadline.
+// Zero values are replaced with the defaults of the endpoint class. Jitter is replaced only if it is nil,
+// so zero Jitter disables the randomization.
+type RetryPolicy struct {
+	MaxAttempts          int           `yml:"max_attempts" yaml:"max_attempts"`
+	InitialDelay         time.Duration `yml:"initial_delay" yaml:"initial_delay"`
+	MaxDelay             time.Duration `yml:"max_delay" yaml:"max_delay"`
+	Multiplier           float64       `yml:"multiplier" yaml:"multiplier"`
+	Jitter               *float64      `yml:"jitter" yaml:"jitter"`
+	RetryableStatusCodes []int         `yml:"retryable_status_codes" yaml:"retryable_status_codes"`
+	MaxRetryAfter        time.Duration `yml:"max_retry_after" yaml:"max_retry_after"`
+}
+
+// RetryPolicies are the retry policies of the endpoint classes.
+type RetryPolicies struct {
+	Configuration RetryPolicy `yml:"configuration" yaml:"configuration"`
+	Tracking      RetryPolicy `yml:"tracking" yaml:"tracking"`
+	RemoteData    RetryPolicy `yml:"remote_data" yaml:"remote_data"`
+	AccessToken   RetryPolicy `yml:"access_token" yaml:"access_token"`
+}
+
+func (p RetryPolicies) WithDefaults() RetryPolicies {
+	p.Configuration = p.Configuration.withDefaults(DefaultConfigurationRetryPolicy)
+	p.Tracking = p.Tracking.withDefaults(DefaultTrackingRetryPolicy)
+	p.RemoteData = p.RemoteData.withDefaults(DefaultRemoteDataRetryPolicy)
+	p.AccessToken = p.AccessToken.withDefaults(DefaultAccessTokenRetryPolicy)
+	return p
+}
+
+func (p RetryPolicy) withDefaults(defaults RetryPolicy) RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = defaults.MaxAttempts
+	}
+	if p.InitialDelay <= 0 {
+		p.InitialDelay = defaults.InitialDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = defaults.MaxDelay
+	}
+	if p.MaxDelay < p.InitialDelay {
+		p.MaxDelay = p.InitialDelay
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = defaults.Multiplier
+		if p.Multiplier < 1 {
+			p.Multiplier = DefaultRetryMultiplier
+		}
+	}
+	if (p.Jitter == nil) || (*p.Jitter < 0) || (*p.Jitter > 1) {
+		jitter := DefaultRetryJitter
+		p.Jitter = &jitter
+	}
+	if p.RetryableStatusCodes == nil {
+		p.RetryableStatusCodes = DefaultRetryableStatusCodes
+	}
+	if p.MaxRetryAfter <= 0 {
+		p.MaxRetryAfter = DefaultRetryMaxRetryAfter
+	}
+	return p
+}
+
+// IsRetryable reports whether the failed response may succeed if the request is repeated.
+func (p RetryPolicy) IsRetryable(response Response) bool {
+	if response.Err != nil {
+		return true
+	}
+	for _, code := range p.RetryableStatusCodes {
+		if code == response.Code {
+			return true
+		}
+	}
+	return false
+}
+
+// Delay returns the delay before the repetition which follows the failed attempts (starting from 1).
+// The second result is false if the server asks to wait longer than MaxRetryAfter.
+func (p RetryPolicy) Delay(attempts int, response Response) (time.Duration, bool) {
+	delay := float64(p.InitialDelay) * math.Pow(p.Multiplier, float64(attempts-1))
+	if delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	if p.Jitter != nil {
+		delay += delay * *p.Jitter * (2*rand.Float64() - 1)
+	}
+	if retryAfter, ok := parseRetryAfter(response.HeadersRead[HeaderRetryAfter], time.Now()); ok {
+		if retryAfter > p.MaxRetryAfter {
+			return retryAfter, false
+		}
+		if retryAfter > time.Duration(delay) {
+			return retryAfter, true
+		}
+	}
+	return time.Duration(delay), true
+}
+
+// parseRetryAfter parses the Retry-After header value, which is either a number of seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if len(value) == 0 {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := date.Sub(now); delay > 0 {
+			return delay, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
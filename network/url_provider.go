@@ -24,6 +24,10 @@ const (
 	rtConfigurationUrlFormat  = "https://%s:8110/sse?%s"
 	accessTokenUrlFormat      = "https://%s/oauth/token"
 	dataApiUrlFormat          = "https://%s%s?%s"
+
+	configurationPath   = "/v3/"
+	rtConfigurationPath = "/sse"
+	accessTokenPath     = "/oauth/token"
 )
 
 type UrlProvider interface {
@@ -54,6 +58,7 @@ type UrlProviderImpl struct {
 	sdkName             string
 	sdkVersion          string
 	isCustomDomain      bool
+	endpointOverride    string
 }
 
 func NewUrlProviderImpl(siteCode string, networkDomain string, sdkName string, sdkVersion string) *UrlProviderImpl {
@@ -70,6 +75,12 @@ func NewUrlProviderImpl(siteCode string, networkDomain string, sdkName string, s
 	return up
 }
 
+// OverrideEndpoint makes all the URLs point to the base URL (scheme://host[:port]) instead of
+// the Kameleoon domains. It is intended for testing against a local stand-in server.
+func (up *UrlProviderImpl) OverrideEndpoint(baseUrl string) {
+	up.endpointOverride = strings.TrimSuffix(baseUrl, "/")
+}
+
 func (up *UrlProviderImpl) SiteCode() string {
 	return up.siteCode
 }
@@ -118,7 +129,7 @@ func (up *UrlProviderImpl) updateDomains(networkDomain string) {
 }
 
 func (up *UrlProviderImpl) ApplyDataApiDomain(dataApiDomain string) {
-	if dataApiDomain != "" {
+	if (dataApiDomain != "") && (up.endpointOverride == "") {
 		if up.isCustomDomain {
 			subDomain := dataApiDomain[:strings.Index(dataApiDomain, ".")]
 			re := regexp.MustCompile("^[^.]+")
@@ -135,7 +146,7 @@ func (up *UrlProviderImpl) MakeTrackingUrl() string {
 	qb.Append(utils.QPSdkVersion, up.sdkVersion)
 	qb.Append(utils.QPSiteCode, up.siteCode)
 	qb.Append(utils.QPBodyUA, "true")
-	return fmt.Sprintf(dataApiUrlFormat, up.dataApiDomain, trackingPath, qb.String())
+	return up.makeDataApiUrl(trackingPath, qb.String())
 }
 
 func (up *UrlProviderImpl) MakeVisitorDataGetUrl(
@@ -158,7 +169,7 @@ func (up *UrlProviderImpl) MakeVisitorDataGetUrl(
 	addFlagParamIfRequired(qb, utils.QPPage, filter.PageViews)
 	addFlagParamIfRequired(qb, utils.QPPersonalization, filter.Personalization)
 	addFlagParamIfRequired(qb, utils.QPCbs, filter.Cbs)
-	return fmt.Sprintf(dataApiUrlFormat, up.dataApiDomain, visitorDataPath, qb.String())
+	return up.makeDataApiUrl(visitorDataPath, qb.String())
 }
 
 func addFlagParamIfRequired(qb *utils.QueryBuilder, paramName string, state bool) {
@@ -171,7 +182,14 @@ func (up *UrlProviderImpl) MakeApiDataGetRequestUrl(key string) string {
 	qb := utils.NewQueryBuilder()
 	qb.Append(utils.QPSiteCode, up.siteCode)
 	qb.Append(utils.QPKey, key)
-	return fmt.Sprintf(dataApiUrlFormat, up.dataApiDomain, getDataPath, qb.String())
+	return up.makeDataApiUrl(getDataPath, qb.String())
+}
+
+func (up *UrlProviderImpl) makeDataApiUrl(path string, query string) string {
+	if up.endpointOverride != "" {
+		return up.endpointOverride + path + "?" + query
+	}
+	return fmt.Sprintf(dataApiUrlFormat, up.dataApiDomain, path, query)
 }
 
 func (up *UrlProviderImpl) MakeConfigurationUrl(environment string, ts int64) string {
@@ -186,7 +204,11 @@ func (up *UrlProviderImpl) MakeConfigurationUrl(environment string, ts int64) st
 		params = append(params, param{name: utils.QPTimestamp, value: fmt.Sprint(ts)})
 	}
 	sb := strings.Builder{}
-	sb.WriteString(fmt.Sprintf(configurationApiUrlFormat, up.configurationDomain, up.siteCode))
+	if up.endpointOverride != "" {
+		sb.WriteString(up.endpointOverride + configurationPath + up.siteCode)
+	} else {
+		sb.WriteString(fmt.Sprintf(configurationApiUrlFormat, up.configurationDomain, up.siteCode))
+	}
 	for i := 0; i < len(params); i++ {
 		if i == 0 {
 			sb.WriteRune('?')
@@ -203,9 +225,15 @@ func (up *UrlProviderImpl) MakeConfigurationUrl(environment string, ts int64) st
 func (up *UrlProviderImpl) MakeRealTimeUrl() string {
 	qb := utils.NewQueryBuilder()
 	qb.Append(utils.QPSiteCode, up.siteCode)
+	if up.endpointOverride != "" {
+		return up.endpointOverride + rtConfigurationPath + "?" + qb.String()
+	}
 	return fmt.Sprintf(rtConfigurationUrlFormat, up.eventsDomain, qb)
 }
 
 func (up *UrlProviderImpl) MakeAccessTokenUrl() string {
+	if up.endpointOverride != "" {
+		return up.endpointOverride + accessTokenPath
+	}
 	return fmt.Sprintf(accessTokenUrlFormat, up.accessTokenDomain)
 }
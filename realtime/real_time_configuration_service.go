@@ -3,29 +3,41 @@ package realtime
 import (
 	"encoding/json"
 	"sync"
+	"time"
 
 	"github.com/Kameleoon/client-go/v3/logging"
-	net "github.com/subchord/go-sse"
 )
 
 const configurationUpdateEvent = "configuration-update-event"
 
 type RealTimeConfigurationService struct {
-	closeFlagMx sync.Mutex
-	url         string
-	updateChan  chan RealTimeEvent
-	sse         SseClient
-	closeFlag   bool
-	closeChan   chan bool
+	closeFlagMx   sync.Mutex
+	url           string
+	updateChan    chan RealTimeEvent
+	sse           SseClient
+	policy        ReconnectPolicy
+	onStateChange func(streaming bool)
+	closeFlag     bool
+	closeChan     chan bool
 }
 
 func NewRealTimeConfigurationService(url string, updateChan chan RealTimeEvent,
 	sse SseClient) *RealTimeConfigurationService {
+	return NewRealTimeConfigurationServiceWithPolicy(url, updateChan, sse, ReconnectPolicy{}, nil)
+}
+
+// NewRealTimeConfigurationServiceWithPolicy creates the service which reconnects according to the policy.
+// onStateChange (optional) is called with false once the reconnection attempts fail ReconnectPolicy.FallbackAfter
+// times in a row, and with true once the stream is open again after that.
+func NewRealTimeConfigurationServiceWithPolicy(url string, updateChan chan RealTimeEvent, sse SseClient,
+	policy ReconnectPolicy, onStateChange func(streaming bool)) *RealTimeConfigurationService {
 	rtcs := &RealTimeConfigurationService{
-		url:        url,
-		updateChan: updateChan,
-		sse:        sse,
-		closeChan:  make(chan bool, 1),
+		url:           url,
+		updateChan:    updateChan,
+		sse:           sse,
+		policy:        policy.WithDefaults(),
+		onStateChange: onStateChange,
+		closeChan:     make(chan bool, 1),
 	}
 	go rtcs.run()
 	return rtcs
@@ -47,13 +59,35 @@ func (rtcs *RealTimeConfigurationService) run() {
 		logging.Error("SSE Client is not provided, Real-time Configuration Service is not started")
 		return
 	}
-	for !rtcs.closeFlag {
+	failures := 0
+	fallenBack := false
+	for !rtcs.isClosed() {
+		if (failures > 0) && !rtcs.sleep(rtcs.policy.Delay(failures)) {
+			break
+		}
 		rtcs.sse.Dispose()
 		if err := rtcs.sse.Init(rtcs.url); err != nil {
-			logging.Error("Failed to open SSE connection: %s", err)
+			failures++
+			// Only the first failure of a series is an error, the rest would flood the log during an outage
+			if failures == 1 {
+				logging.Error("Failed to open SSE connection: %s", err)
+			} else {
+				logging.Debug("Failed to open SSE connection (attempt %s): %s", failures, err)
+			}
+			if !fallenBack && (failures >= rtcs.policy.FallbackAfter) {
+				fallenBack = true
+				logging.Warning("SSE connection failed %s times in a row, falling back to polling", failures)
+				rtcs.notifyStateChange(false)
+			}
 			continue
 		}
 		logging.Info("SSE connection open")
+		if fallenBack {
+			fallenBack = false
+			logging.Info("SSE connection recovered, switching back to streaming")
+			rtcs.notifyStateChange(true)
+		}
+		openedAt := time.Now()
 		for halt := false; !halt; {
 			select {
 			case halt = <-rtcs.closeChan:
@@ -67,6 +101,10 @@ func (rtcs *RealTimeConfigurationService) run() {
 					halt = true
 					logging.Error("Error occurred within SSE client: %s", err)
 				case evt := <-rtcs.sse.GetEventChan():
+					if evt.Name != configurationUpdateEvent {
+						logging.Debug("Skipped %s SSE event", evt.Name)
+						break
+					}
 					logging.Info("Got %s SSE event", configurationUpdateEvent)
 					if err := rtcs.handleEvent(evt); err != nil {
 						logging.Error("Error occurred during SSE event parsing: %s", err)
@@ -75,14 +113,43 @@ func (rtcs *RealTimeConfigurationService) run() {
 			}
 		}
 		logging.Info("SSE connection closed")
+		if time.Since(openedAt) >= rtcs.policy.HealthyConnectionAt {
+			failures = 0
+		}
+		// A dropped connection is reconnected with a delay as well
+		failures++
 	}
 	close(rtcs.updateChan)
 	rtcs.sse.Dispose()
 	logging.Info("Real-Time Configuration Service stopped")
 }
 
-func (rtcs *RealTimeConfigurationService) handleEvent(evt net.Event) error {
-	b := []byte(evt.GetData())
+func (rtcs *RealTimeConfigurationService) isClosed() bool {
+	rtcs.closeFlagMx.Lock()
+	defer rtcs.closeFlagMx.Unlock()
+	return rtcs.closeFlag
+}
+
+// sleep waits for the delay and returns false if the service is closed meanwhile.
+func (rtcs *RealTimeConfigurationService) sleep(delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-rtcs.closeChan:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func (rtcs *RealTimeConfigurationService) notifyStateChange(streaming bool) {
+	if rtcs.onStateChange != nil {
+		rtcs.onStateChange(streaming)
+	}
+}
+
+func (rtcs *RealTimeConfigurationService) handleEvent(evt Event) error {
+	b := []byte(evt.Data)
 	var rtEvent RealTimeEvent
 	if err := json.Unmarshal(b, &rtEvent); err != nil {
 		return err
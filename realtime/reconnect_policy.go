@@ -0,0 +1,73 @@
+package realtime
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+const (
+	DefaultReconnectInitialDelay        = time.Second
+	DefaultReconnectMaxDelay            = time.Minute
+	DefaultReconnectMultiplier          = 2.0
+	DefaultReconnectJitter              = 0.2
+	DefaultReconnectFallbackAfter       = 5
+	DefaultReconnectHealthyConnectionAt = 30 * time.Second
+)
+
+// ReconnectPolicy defines the delays between SSE reconnection attempts.
+//
+// The delay grows exponentially from InitialDelay up to MaxDelay, and a random part of Jitter ratio is added
+// or subtracted. The delay is reset after a connection stays open for HealthyConnectionAt.
+// After FallbackAfter consecutive failures the configuration is polled until the stream recovers.
+// Zero values are replaced with the defaults. Jitter is replaced only if it is nil, so zero Jitter disables
+// the randomization.
+type ReconnectPolicy struct {
+	InitialDelay        time.Duration `yml:"initial_delay" yaml:"initial_delay"`
+	MaxDelay            time.Duration `yml:"max_delay" yaml:"max_delay"`
+	Multiplier          float64       `yml:"multiplier" yaml:"multiplier"`
+	Jitter              *float64      `yml:"jitter" yaml:"jitter"`
+	FallbackAfter       int           `yml:"fallback_after" yaml:"fallback_after"`
+	HealthyConnectionAt time.Duration `yml:"healthy_connection_at" yaml:"healthy_connection_at"`
+}
+
+func (p ReconnectPolicy) WithDefaults() ReconnectPolicy {
+	if p.InitialDelay <= 0 {
+		p.InitialDelay = DefaultReconnectInitialDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = DefaultReconnectMaxDelay
+	}
+	if p.MaxDelay < p.InitialDelay {
+		p.MaxDelay = p.InitialDelay
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = DefaultReconnectMultiplier
+	}
+	if (p.Jitter == nil) || (*p.Jitter < 0) || (*p.Jitter > 1) {
+		jitter := DefaultReconnectJitter
+		p.Jitter = &jitter
+	}
+	if p.FallbackAfter <= 0 {
+		p.FallbackAfter = DefaultReconnectFallbackAfter
+	}
+	if p.HealthyConnectionAt <= 0 {
+		p.HealthyConnectionAt = DefaultReconnectHealthyConnectionAt
+	}
+	return p
+}
+
+// Delay returns the delay before the reconnection attempt which follows the failures in a row.
+func (p ReconnectPolicy) Delay(failures int) time.Duration {
+	if failures <= 0 {
+		return 0
+	}
+	delay := float64(p.InitialDelay) * math.Pow(p.Multiplier, float64(failures-1))
+	if delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	if p.Jitter != nil {
+		delay += delay * *p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(delay)
+}
@@ -1,77 +1,266 @@
 package realtime
 
 import (
-	"github.com/Kameleoon/client-go/v3/logging"
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
 
-	net "github.com/subchord/go-sse"
+	"github.com/Kameleoon/client-go/v3/logging"
 )
 
 type SseClient interface {
 	Init(url string) error
 	Dispose()
 	GetErrorChan() <-chan error
-	GetEventChan() <-chan net.Event
+	GetEventChan() <-chan Event
 }
 
-type NetSseClient struct {
-	feed *net.SSEFeed
-	sub  *net.Subscription
+// Event is a server-sent event. Name is "message" if the event has no `event` field.
+type Event struct {
+	Id   string
+	Name string
+	Data string
 }
 
-func (sse *NetSseClient) Init(url string) error {
-	if err := sse.initFeed(url); err != nil {
-		return err
+const (
+	defaultSseEventName = "message"
+	lastEventIdHeader   = "Last-Event-ID"
+)
+
+// SseClientConfig is the configuration of HttpSseClient. Zero values mean no limit.
+type SseClientConfig struct {
+	// ProxyURL overrides the proxy set by the environment (HTTP_PROXY, HTTPS_PROXY and NO_PROXY).
+	ProxyURL string
+	// TLSConfig (optional) is used for HTTPS streams, the default configuration is used if it is nil
+	TLSConfig *tls.Config
+	// ConnectTimeout limits dialing and waiting for the response headers
+	ConnectTimeout time.Duration
+	// HeartbeatTimeout is the longest silence of an open stream, after which the stream is considered dead.
+	// Any line (including comments) sent by the server resets it.
+	HeartbeatTimeout time.Duration
+	// Transport (optional) replaces the transport of the stream. ProxyURL, TLSConfig and ConnectTimeout
+	// are not applied to it.
+	Transport http.RoundTripper
+}
+
+// HttpSseClient is the SseClient implementation on net/http.
+//
+// The ID of the last received event is kept between connections and sent with the Last-Event-ID header,
+// so the server is able to resume the stream.
+type HttpSseClient struct {
+	mx               sync.Mutex
+	client           *http.Client
+	heartbeatTimeout time.Duration
+	lastEventId      string
+	cancel           context.CancelFunc
+	eventChan        chan Event
+	errorChan        chan error
+}
+
+func NewHttpSseClient(cfg SseClientConfig) *HttpSseClient {
+	if cfg.Transport != nil {
+		return &HttpSseClient{
+			client:           &http.Client{Transport: cfg.Transport},
+			heartbeatTimeout: cfg.HeartbeatTimeout,
+		}
 	}
-	if err := sse.initSub(); err != nil {
-		return err
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: cfg.ConnectTimeout}).DialContext,
+		TLSClientConfig:       cfg.TLSConfig,
+		TLSHandshakeTimeout:   cfg.ConnectTimeout,
+		ResponseHeaderTimeout: cfg.ConnectTimeout,
+		ForceAttemptHTTP2:     true,
+	}
+	if len(cfg.ProxyURL) > 0 {
+		if proxyUrl, err := url.Parse(cfg.ProxyURL); err == nil {
+			transport.Proxy = http.ProxyURL(proxyUrl)
+		} else {
+			logging.Error("Invalid proxy URL %s is ignored by the SSE client: %s", cfg.ProxyURL, err)
+		}
+	}
+	return &HttpSseClient{
+		// No client timeout, it would break the stream
+		client:           &http.Client{Transport: transport},
+		heartbeatTimeout: cfg.HeartbeatTimeout,
 	}
-	return nil
 }
 
-func (sse *NetSseClient) initFeed(url string) error {
-	headers := map[string][]string{
-		http.CanonicalHeaderKey("Accept"):        {"text/event-stream"},
-		http.CanonicalHeaderKey("Cache-Control"): {"no-cache"},
-		http.CanonicalHeaderKey("Connection"):    {"Keep-Alive"},
+func (sse *HttpSseClient) Init(url string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		cancel()
+		return err
 	}
-	feed, err := net.ConnectWithSSEFeed(url, headers)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Cache-Control", "no-cache")
+	sse.mx.Lock()
+	if len(sse.lastEventId) > 0 {
+		req.Header.Set(lastEventIdHeader, sse.lastEventId)
+	}
+	sse.mx.Unlock()
+	resp, err := sse.client.Do(req)
 	if err != nil {
+		cancel()
 		return err
 	}
-	sse.feed = feed
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		cancel()
+		return fmt.Errorf("unexpected SSE response status code %d", resp.StatusCode)
+	}
+	eventChan := make(chan Event)
+	errorChan := make(chan error, 1)
+	sse.mx.Lock()
+	if sse.cancel != nil {
+		sse.cancel()
+	}
+	sse.cancel = cancel
+	sse.eventChan = eventChan
+	sse.errorChan = errorChan
+	sse.mx.Unlock()
+	go sse.read(ctx, cancel, resp.Body, eventChan, errorChan)
 	return nil
 }
 
-func (sse *NetSseClient) initSub() error {
-	sub, err := sse.feed.Subscribe(configurationUpdateEvent)
-	if err != nil {
-		return err
+// Dispose closes the current stream. The channels are not closed, they just stop receiving values.
+func (sse *HttpSseClient) Dispose() {
+	sse.mx.Lock()
+	defer sse.mx.Unlock()
+	if sse.cancel != nil {
+		sse.cancel()
+		sse.cancel = nil
 	}
-	sse.sub = sub
-	return nil
 }
 
-func (sse *NetSseClient) Dispose() {
-	defer func() { // "close of closed channel" panic occurs
-		if err := recover(); err != nil {
-			logging.Warning("Panic occurred during SSE dispose process: %s", err)
-		}
-	}()
-	if sse.feed != nil {
-		sse.feed.Close()
-		sse.feed = nil
+func (sse *HttpSseClient) GetErrorChan() <-chan error {
+	sse.mx.Lock()
+	defer sse.mx.Unlock()
+	return sse.errorChan
+}
+
+func (sse *HttpSseClient) GetEventChan() <-chan Event {
+	sse.mx.Lock()
+	defer sse.mx.Unlock()
+	return sse.eventChan
+}
+
+func (sse *HttpSseClient) read(ctx context.Context, cancel context.CancelFunc, body io.ReadCloser,
+	eventChan chan<- Event, errorChan chan<- error) {
+	defer body.Close()
+	defer cancel()
+	var heartbeatMx sync.Mutex
+	heartbeatExpired := false
+	var heartbeat *time.Timer
+	if sse.heartbeatTimeout > 0 {
+		// Cancelling the context aborts the pending read of the body
+		heartbeat = time.AfterFunc(sse.heartbeatTimeout, func() {
+			heartbeatMx.Lock()
+			heartbeatExpired = true
+			heartbeatMx.Unlock()
+			cancel()
+		})
+		defer heartbeat.Stop()
 	}
-	if sse.sub != nil {
-		sse.sub.Close()
-		sse.sub = nil
+	parser := sseParser{lastEventId: sse.getLastEventId()}
+	reader := bufio.NewReader(body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			heartbeatMx.Lock()
+			expired := heartbeatExpired
+			heartbeatMx.Unlock()
+			if expired {
+				err = fmt.Errorf("no data received within the SSE heartbeat timeout of %s", sse.heartbeatTimeout)
+			} else if errors.Is(err, io.EOF) {
+				err = errors.New("SSE stream closed by the server")
+			}
+			// Nobody waits for the error of a disposed stream
+			if expired || (ctx.Err() == nil) {
+				errorChan <- err
+			}
+			return
+		}
+		if heartbeat != nil {
+			heartbeat.Reset(sse.heartbeatTimeout)
+		}
+		if evt, ok := parser.parseLine(strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")); ok {
+			sse.setLastEventId(evt.Id)
+			select {
+			case eventChan <- evt:
+			case <-ctx.Done():
+				return
+			}
+		}
 	}
 }
 
-func (sse *NetSseClient) GetErrorChan() <-chan error {
-	return sse.sub.ErrFeed()
+func (sse *HttpSseClient) getLastEventId() string {
+	sse.mx.Lock()
+	defer sse.mx.Unlock()
+	return sse.lastEventId
+}
+
+func (sse *HttpSseClient) setLastEventId(id string) {
+	sse.mx.Lock()
+	defer sse.mx.Unlock()
+	sse.lastEventId = id
 }
 
-func (sse *NetSseClient) GetEventChan() <-chan net.Event {
-	return sse.sub.Feed()
+// sseParser parses the event stream format line by line as defined by the HTML specification.
+type sseParser struct {
+	lastEventId string
+	name        string
+	data        strings.Builder
+}
+
+// parseLine returns the event which is completed by the line, if any.
+func (p *sseParser) parseLine(line string) (Event, bool) {
+	if len(line) == 0 {
+		return p.dispatch()
+	}
+	if strings.HasPrefix(line, ":") {
+		return Event{}, false // comment, e.g. a heartbeat
+	}
+	field, value := line, ""
+	if i := strings.IndexByte(line, ':'); i >= 0 {
+		field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+	}
+	switch field {
+	case "event":
+		p.name = value
+	case "data":
+		p.data.WriteString(value)
+		p.data.WriteByte('\n')
+	case "id":
+		if !strings.ContainsRune(value, 0) {
+			p.lastEventId = value
+		}
+	}
+	// "retry" is ignored, the reconnection delays are defined by ReconnectPolicy
+	return Event{}, false
+}
+
+func (p *sseParser) dispatch() (Event, bool) {
+	name := p.name
+	p.name = ""
+	if p.data.Len() == 0 {
+		return Event{}, false
+	}
+	data := strings.TrimSuffix(p.data.String(), "\n")
+	p.data.Reset()
+	if len(name) == 0 {
+		name = defaultSseEventName
+	}
+	return Event{Id: p.lastEventId, Name: name, Data: data}, true
 }
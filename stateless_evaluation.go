@@ -0,0 +1,185 @@
+package kameleoon
+
+import (
+	"github.com/Kameleoon/client-go/v3/errs"
+	"github.com/Kameleoon/client-go/v3/logging"
+	"github.com/Kameleoon/client-go/v3/managers/data"
+	"github.com/Kameleoon/client-go/v3/storage"
+	"github.com/Kameleoon/client-go/v3/types"
+	"github.com/Kameleoon/client-go/v3/utils"
+)
+
+type StatelessOptParams struct {
+	track        bool
+	onlyActive   bool
+	legalConsent types.LegalConsent
+}
+
+func NewStatelessOptParams() StatelessOptParams {
+	return StatelessOptParams{track: true}
+}
+
+// Track sets whether the assigned variations are returned as sendable data.
+func (p StatelessOptParams) Track(value bool) StatelessOptParams {
+	p.track = value
+	return p
+}
+
+// OnlyActive sets whether GetVariationsStateless omits the feature flags with the "off" variation.
+func (p StatelessOptParams) OnlyActive(value bool) StatelessOptParams {
+	p.onlyActive = value
+	return p
+}
+
+// LegalConsent sets whether the visitor gave the legal consent. It matters only if the consent is required,
+// the consent is unknown by default the same way as for a new visitor.
+func (p StatelessOptParams) LegalConsent(value bool) StatelessOptParams {
+	if value {
+		p.legalConsent = types.LegalConsentGiven
+	} else {
+		p.legalConsent = types.LegalConsentNotGiven
+	}
+	return p
+}
+
+// IsTracked returns the value set with Track.
+func (p StatelessOptParams) IsTracked() bool {
+	return p.track
+}
+
+// IsOnlyActive returns the value set with OnlyActive.
+func (p StatelessOptParams) IsOnlyActive() bool {
+	return p.onlyActive
+}
+
+func (c *kameleoonClient) GetVariationStateless(
+	visitorCode string, featureKey string, visitorData []types.BaseData, params ...StatelessOptParams,
+) (externalVariation types.Variation, sendables []types.Sendable, err error) {
+	logging.Info(
+		"CALL: kameleoonClient.GetVariationStateless(visitorCode: %s, featureKey: %s, visitorData: %s, "+
+			"params: %s)", visitorCode, featureKey, visitorData, params,
+	)
+	defer func() {
+		logging.Info(
+			"RETURN: kameleoonClient.GetVariationStateless(visitorCode: %s, featureKey: %s, visitorData: %s, "+
+				"params: %s) -> (variation: %s, sendables: %s, err: %s)",
+			visitorCode, featureKey, visitorData, params, externalVariation, sendables, err,
+		)
+	}()
+	p := statelessOptParams(params)
+	if err = utils.ValidateVisitorCode(visitorCode); err != nil {
+		return
+	}
+	evaluator, visitor, supplied := c.newStatelessEvaluator(visitorCode, visitorData, p)
+	var featureFlag types.IFeatureFlag
+	if featureFlag, err = evaluator.dataManager.DataFile().GetFeatureFlag(featureKey); err != nil {
+		return
+	}
+	var variationKey string
+	var evalExp *evaluatedExperiment
+	if variationKey, evalExp, err = evaluator.getVariationInfo(visitorCode, featureFlag, p.track); err != nil {
+		return
+	}
+	variation, _ := featureFlag.GetVariationByKey(variationKey)
+	externalVariation = createExternalVariation(variation, evalExp)
+	sendables = evaluator.collectAssignedVariations(visitor, supplied)
+	return
+}
+
+func (c *kameleoonClient) GetVariationsStateless(
+	visitorCode string, visitorData []types.BaseData, params ...StatelessOptParams,
+) (variations map[string]types.Variation, sendables []types.Sendable, err error) {
+	logging.Info(
+		"CALL: kameleoonClient.GetVariationsStateless(visitorCode: %s, visitorData: %s, params: %s)",
+		visitorCode, visitorData, params,
+	)
+	defer func() {
+		logging.Info(
+			"RETURN: kameleoonClient.GetVariationsStateless(visitorCode: %s, visitorData: %s, params: %s) -> "+
+				"(variations: %s, sendables: %s, err: %s)", visitorCode, visitorData, params, variations, sendables, err,
+		)
+	}()
+	p := statelessOptParams(params)
+	if err = utils.ValidateVisitorCode(visitorCode); err != nil {
+		return
+	}
+	evaluator, visitor, supplied := c.newStatelessEvaluator(visitorCode, visitorData, p)
+	variations = make(map[string]types.Variation)
+	for _, ff := range evaluator.dataManager.DataFile().GetOrderedFeatureFlags() {
+		if !ff.GetEnvironmentEnabled() {
+			continue
+		}
+		variationKey, evalExp, evalErr := evaluator.getVariationInfo(visitorCode, ff, p.track)
+		if evalErr != nil {
+			if _, disabled := evalErr.(*errs.FeatureEnvironmentDisabled); disabled {
+				continue
+			}
+			return nil, nil, evalErr
+		}
+		if p.onlyActive && (variationKey == string(types.VariationOff)) {
+			continue
+		}
+		variation, _ := ff.GetVariationByKey(variationKey)
+		variations[ff.GetFeatureKey()] = createExternalVariation(variation, evalExp)
+	}
+	sendables = evaluator.collectAssignedVariations(visitor, supplied)
+	return
+}
+
+func statelessOptParams(params []StatelessOptParams) StatelessOptParams {
+	if len(params) > 0 {
+		return params[0]
+	}
+	return NewStatelessOptParams()
+}
+
+// newStatelessEvaluator returns an evaluator of the visitor made of the visitor data only,
+// along with the visitor and its supplied assigned variations.
+// The evaluator keeps the visitor in a detached visitor manager, so the visitors of the client are neither read
+// nor written, and nothing is tracked.
+func (c *kameleoonClient) newStatelessEvaluator(
+	visitorCode string, visitorData []types.BaseData, p StatelessOptParams,
+) (*variationEvaluator, storage.Visitor, map[*types.AssignedVariation]struct{}) {
+	dataFile := c.dataManager.DataFile()
+	vm := storage.NewDetachedVisitorManager(data.NewDataManagerImpl(dataFile))
+	// The external data is processed by the visitor manager, e.g. the custom data are mapped by name,
+	// while the visitor state like the assigned variations is added as is
+	var externalData []types.Data
+	var stateData []types.BaseData
+	for _, d := range visitorData {
+		if ed, ok := d.(types.Data); ok {
+			externalData = append(externalData, ed)
+		} else {
+			stateData = append(stateData, d)
+		}
+	}
+	visitor := vm.AddData(visitorCode, externalData...)
+	visitor.AddBaseData(true, stateData...)
+	visitor.SetLegalConsent(p.legalConsent)
+	supplied := make(map[*types.AssignedVariation]struct{})
+	visitor.Variations().Enumerate(func(av *types.AssignedVariation) bool {
+		supplied[av] = struct{}{}
+		return true
+	})
+	return newVariationEvaluator(dataFile, vm), visitor, supplied
+}
+
+// collectAssignedVariations returns the variations assigned by the evaluation, the supplied ones are skipped.
+// Without the legal consent only the targeted delivery variations are returned, the same way as they are tracked.
+func (e *variationEvaluator) collectAssignedVariations(
+	visitor storage.Visitor, supplied map[*types.AssignedVariation]struct{},
+) []types.Sendable {
+	isConsentGiven := !e.dataManager.DataFile().Settings().IsConsentRequired() ||
+		(visitor.LegalConsent() == types.LegalConsentGiven)
+	var sendables []types.Sendable
+	visitor.Variations().Enumerate(func(av *types.AssignedVariation) bool {
+		if _, exists := supplied[av]; exists || !av.Unsent() {
+			return true
+		}
+		if isConsentGiven || (av.RuleType() == types.RuleTypeTargetedDelivery) {
+			sendables = append(sendables, av)
+		}
+		return true
+	})
+	return sendables
+}
@@ -362,6 +362,68 @@ func (v *VisitorImpl) Clone() Visitor {
 	return cloneVisitorImpl(v)
 }
 
+// ApproximateSize returns a rough estimate of the memory used by the visitor in bytes.
+func (v *VisitorImpl) ApproximateSize(visitorCode string) int {
+	vd := v.data
+	vd.mx.RLock()
+	defer vd.mx.RUnlock()
+	size := visitorBaseSize + len(visitorCode) + len(vd.userAgent)
+	for _, set := range []bool{
+		vd.device != nil, vd.applicationVersion != nil, vd.browser != nil, vd.operatingSystem != nil,
+		vd.geolocation != nil,
+	} {
+		if set {
+			size += visitorDataBaseSize
+		}
+	}
+	if vd.cookie != nil {
+		for key, value := range vd.cookie.Cookies() {
+			size += visitorMapEntryFactor + len(key) + len(value)
+		}
+	}
+	if vd.kcsHeat != nil {
+		for _, values := range vd.kcsHeat.Values() {
+			size += visitorMapEntryFactor * (len(values) + 1)
+		}
+	}
+	if vd.cbscores != nil {
+		for _, groups := range vd.cbscores.Values() {
+			for _, group := range groups {
+				size += visitorMapEntryFactor * (len(group.Ids()) + 1)
+			}
+		}
+	}
+	if vd.visitorVisits != nil {
+		size += visitorDataBaseSize + visitorMapEntryFactor*len(vd.visitorVisits.PrevVisits())
+	}
+	for _, cd := range vd.customDataMap {
+		size += approximateCustomDataSize(cd.Name(), cd.Values())
+	}
+	for url, pvv := range vd.pageViewVisits {
+		size += visitorDataBaseSize + len(url) + len(pvv.PageView.Title()) +
+			visitorMapEntryFactor*len(pvv.PageView.Referrers())
+	}
+	for _, c := range vd.conversions {
+		size += visitorDataBaseSize
+		for _, cd := range c.Metadata() {
+			if cd != nil {
+				size += approximateCustomDataSize(cd.Name(), cd.Values())
+			}
+		}
+	}
+	size += visitorDataBaseSize * (len(vd.variations) + len(vd.personalizations) + len(vd.targetedSegments) +
+		len(vd.forcedVariations) + len(vd.simulatedVariations))
+	return size
+}
+
+func approximateCustomDataSize(name string, values []string) int {
+	size := visitorDataBaseSize + len(name)
+	for _, value := range values {
+		size += visitorMapEntryFactor + len(value)
+	}
+	return size
+}
+
 type visitorData struct {
 	mx                  sync.RWMutex
 	timeStarted         time.Time
@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/Kameleoon/client-go/v3/logging"
+	"github.com/Kameleoon/client-go/v3/types"
+)
+
+const (
+	// Limits are enforced down to the watermark, so eviction does not run on every new visitor
+	evictionWatermark     = 0.9
+	DefaultOvershoot      = 0.5
+	memoryCheckInterval   = 5 * time.Second
+	visitorBaseSize       = 512
+	visitorDataBaseSize   = 64
+	visitorMapEntryFactor = 16
+)
+
+// VisitorLimits bounds the number of visitors kept by VisitorManagerImpl and their approximate memory usage.
+// Zero means no limit. When a limit is exceeded, the least recently active visitors are evicted in the
+// background, so the storage may exceed the limits for a short time.
+//
+// The visitors with undelivered data are kept while the storage exceeds the limits by no more than
+// the Overshoot ratio (DefaultOvershoot if it is zero). Past it they are evicted anyway and their data is lost.
+type VisitorLimits struct {
+	MaxVisitors int
+	MaxBytes    int64
+	Overshoot   float64
+}
+
+func (l VisitorLimits) overshoot() float64 {
+	if l.Overshoot <= 0 {
+		return DefaultOvershoot
+	}
+	return l.Overshoot
+}
+
+// VisitorStorageStats describes the state of the visitor storage.
+type VisitorStorageStats struct {
+	Visitors         int
+	ApproximateBytes int64
+	// Evictions is the total number of visitors evicted because of VisitorLimits
+	Evictions uint64
+	// DroppedVisitors is the number of the evicted visitors whose undelivered data was lost
+	// because the storage exceeded the overshoot of the limits, it is included in Evictions
+	DroppedVisitors uint64
+}
+
+// SetEvictionHandler sets the handler which is called once per eviction round with the visitors to be evicted
+// which have unsent data. It is used to flush the data through the tracking manager. The visitors are kept
+// until all their data is delivered, so the data of a failed request is not lost, and they are evicted
+// by one of the next rounds.
+func (vm *VisitorManagerImpl) SetEvictionHandler(handler func(visitorCodes []string)) {
+	vm.evictionMx.Lock()
+	defer vm.evictionMx.Unlock()
+	vm.evictionHandler = handler
+}
+
+func (vm *VisitorManagerImpl) Stats() VisitorStorageStats {
+	stats := VisitorStorageStats{
+		Evictions:       atomic.LoadUint64(&vm.evictions),
+		DroppedVisitors: atomic.LoadUint64(&vm.droppedVisitors),
+	}
+	vm.visitors.Enumerate(func(vc string, v *VisitorImpl) bool {
+		stats.Visitors++
+		stats.ApproximateBytes += int64(v.ApproximateSize(vc))
+		return true
+	})
+	return stats
+}
+
+// onVisitorCreated requests the eviction from the background goroutine, so the requests creating visitors
+// never wait for the storage to be scanned.
+func (vm *VisitorManagerImpl) onVisitorCreated() {
+	if (vm.limits.MaxVisitors > 0) && (vm.visitors.Len() > vm.limits.MaxVisitors) {
+		select {
+		case vm.evictionSignal <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// enforceLimits evicts the least recently active visitors until both limits are satisfied.
+func (vm *VisitorManagerImpl) enforceLimits() {
+	// One eviction at a time is enough, concurrent callers would evict the same visitors
+	if !vm.evictionMx.TryLock() {
+		return
+	}
+	defer vm.evictionMx.Unlock()
+	logging.Debug("CALL: VisitorManagerImpl.enforceLimits()")
+	type candidate struct {
+		vc           string
+		v            *VisitorImpl
+		lastActivity time.Time
+		size         int64
+	}
+	var candidates []candidate
+	var totalBytes int64
+	vm.visitors.Enumerate(func(vc string, v *VisitorImpl) bool {
+		c := candidate{vc: vc, v: v, lastActivity: v.LastActivityTime()}
+		if vm.limits.MaxBytes > 0 {
+			c.size = int64(v.ApproximateSize(vc))
+			totalBytes += c.size
+		}
+		candidates = append(candidates, c)
+		return true
+	})
+	count := len(candidates)
+	exceeded := func(count int, bytes int64, watermark float64) bool {
+		return ((vm.limits.MaxVisitors > 0) && (float64(count) > float64(vm.limits.MaxVisitors)*watermark)) ||
+			((vm.limits.MaxBytes > 0) && (float64(bytes) > float64(vm.limits.MaxBytes)*watermark))
+	}
+	if !exceeded(count, totalBytes, 1) {
+		logging.Debug("RETURN: VisitorManagerImpl.enforceLimits()")
+		return
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].lastActivity.Before(candidates[j].lastActivity)
+	})
+	evicted, dropped := 0, 0
+	var kept []candidate
+	for _, c := range candidates {
+		if !exceeded(count, totalBytes, evictionWatermark) {
+			break
+		}
+		if (vm.evictionHandler != nil) && hasUndeliveredData(c.v) {
+			kept = append(kept, c)
+			continue
+		}
+		// A visitor which became active meanwhile is not removed, so it frees nothing
+		if vm.evict(c.vc, c.v) {
+			evicted++
+			count--
+			totalBytes -= c.size
+		}
+	}
+	// Past the overshoot the memory matters more than the undelivered data, e.g. while the Data API is down
+	var toFlush []string
+	for _, c := range kept {
+		if exceeded(count, totalBytes, 1+vm.limits.overshoot()) && vm.evict(c.vc, c.v) {
+			dropped++
+			count--
+			totalBytes -= c.size
+		} else if hasUnsentData(c.v) {
+			toFlush = append(toFlush, c.vc)
+		}
+	}
+	if len(toFlush) > 0 {
+		vm.evictionHandler(toFlush)
+	}
+	if evicted+dropped > 0 {
+		atomic.AddUint64(&vm.evictions, uint64(evicted+dropped))
+		logging.Info("%s visitors were evicted because the visitor storage limits were exceeded", evicted+dropped)
+	}
+	if dropped > 0 {
+		atomic.AddUint64(&vm.droppedVisitors, uint64(dropped))
+		logging.Warning("%s visitors were evicted with undelivered data because the visitor storage exceeded "+
+			"the limits by more than %s", dropped, vm.limits.overshoot())
+	}
+	logging.Debug("RETURN: VisitorManagerImpl.enforceLimits()")
+}
+
+func (vm *VisitorManagerImpl) evict(visitorCode string, visitor *VisitorImpl) bool {
+	lastActivity := visitor.LastActivityTime()
+	evicted := false
+	vm.visitors.RemoveIf(visitorCode, func(v *VisitorImpl) bool {
+		evicted = (v == visitor) && !v.LastActivityTime().After(lastActivity)
+		return evicted
+	})
+	return evicted
+}
+
+func hasUndeliveredData(visitor *VisitorImpl) bool {
+	undelivered := false
+	visitor.EnumerateSendableData(func(s types.Sendable) bool {
+		undelivered = !s.Sent()
+		return !undelivered
+	})
+	return undelivered
+}
+
+func hasUnsentData(visitor *VisitorImpl) bool {
+	unsent := false
+	visitor.EnumerateSendableData(func(s types.Sendable) bool {
+		unsent = s.Unsent()
+		return !unsent
+	})
+	return unsent
+}
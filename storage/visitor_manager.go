@@ -1,12 +1,12 @@
 package storage
 
 import (
+	"sync"
 	"time"
 
 	"github.com/Kameleoon/client-go/v3/logging"
 	"github.com/Kameleoon/client-go/v3/managers/data"
 	"github.com/Kameleoon/client-go/v3/types"
-	cmap "github.com/orcaman/concurrent-map/v2"
 )
 
 type VisitorManager interface {
@@ -16,46 +16,97 @@ type VisitorManager interface {
 	AddData(visitorCode string, data ...types.Data) Visitor
 	AddDataWithTrack(visitorCode string, track bool, data ...types.Data) Visitor
 
+	// Persist writes the visitor back to the store after it is changed in place.
+	Persist(visitorCode string, visitor Visitor)
+
 	Enumerate(f func(string, Visitor) bool)
 	Len() int
 
 	Clear()
 
+	Stats() VisitorStorageStats
+
 	Close()
 }
 
 type VisitorManagerImpl struct {
-	dataManager      data.DataManager
-	visitors         cmap.ConcurrentMap[string, *VisitorImpl]
-	expirationPeriod time.Duration
-	purgeTicker      *time.Ticker
-	stopChan         chan struct{}
+	evictions         uint64 // accessed atomically, must be first for alignment
+	droppedVisitors   uint64 // accessed atomically
+	dataManager       data.DataManager
+	visitors          VisitorStore
+	expirationPeriod  time.Duration
+	limits            VisitorLimits
+	evictionMx        sync.Mutex
+	evictionHandler   func(visitorCodes []string)
+	evictionSignal    chan struct{}
+	purgeTicker       *time.Ticker
+	memoryCheckTicker *time.Ticker
+	stopChan          chan struct{}
 }
 
 func NewVisitorManagerImpl(
 	dataManager data.DataManager, expirationPeriod time.Duration,
 ) *VisitorManagerImpl {
-	logging.Debug("CALL: NewVisitorManagerImpl(expirationPeriod: %s)", expirationPeriod)
+	return NewVisitorManagerImplWithStore(dataManager, expirationPeriod, nil, VisitorLimits{})
+}
+
+// NewVisitorManagerImplWithStore creates a visitor manager backed by the store.
+// The in-memory store is used if the store is nil.
+func NewVisitorManagerImplWithStore(
+	dataManager data.DataManager, expirationPeriod time.Duration, store VisitorStore, limits VisitorLimits,
+) *VisitorManagerImpl {
+	logging.Debug("CALL: NewVisitorManagerImplWithStore(expirationPeriod: %s, store, limits: %+v)",
+		expirationPeriod, limits)
+	if store == nil {
+		store = NewInMemoryVisitorStore()
+	}
 	vm := &VisitorManagerImpl{
 		dataManager:      dataManager,
-		visitors:         cmap.New[*VisitorImpl](),
+		visitors:         store,
 		expirationPeriod: expirationPeriod,
+		limits:           limits,
 		purgeTicker:      time.NewTicker(expirationPeriod),
 		stopChan:         make(chan struct{}, 8),
+		evictionSignal:   make(chan struct{}, 1),
+	}
+	vm.startBackgroundTasks()
+	logging.Debug("RETURN: NewVisitorManagerImplWithStore(expirationPeriod: %s, store, limits: %+v) -> "+
+		"(VisitorManagerImpl)", expirationPeriod, limits)
+	return vm
+}
+
+// NewDetachedVisitorManager creates a visitor manager for short-lived visitors which are dropped together
+// with the manager, e.g. the visitors of a single stateless evaluation.
+// It has no expiration and no limits, so it runs no background tasks.
+func NewDetachedVisitorManager(dataManager data.DataManager) *VisitorManagerImpl {
+	return &VisitorManagerImpl{
+		dataManager: dataManager,
+		visitors:    NewInMemoryVisitorStore(),
+		stopChan:    make(chan struct{}, 8),
+	}
+}
+
+func (vm *VisitorManagerImpl) startBackgroundTasks() {
+	// The memory usage changes with every added data, so it is checked periodically
+	var memoryCheckChan <-chan time.Time
+	if vm.limits.MaxBytes > 0 {
+		vm.memoryCheckTicker = time.NewTicker(memoryCheckInterval)
+		memoryCheckChan = vm.memoryCheckTicker.C
 	}
 	go func() {
 		for {
 			select {
 			case <-vm.purgeTicker.C:
 				vm.purge()
+			case <-memoryCheckChan:
+				vm.enforceLimits()
+			case <-vm.evictionSignal:
+				vm.enforceLimits()
 			case <-vm.stopChan:
 				return
 			}
 		}
 	}()
-	logging.Debug("RETURN: NewVisitorManagerImpl(expirationPeriod: %s) -> (VisitorManagerImpl)",
-		expirationPeriod)
-	return vm
 }
 
 func (vm *VisitorManagerImpl) ExpirationPeriod() time.Duration {
@@ -67,7 +118,12 @@ func (vm *VisitorManagerImpl) Close() {
 }
 func (vm *VisitorManagerImpl) stop() {
 	logging.Debug("CALL: VisitorManagerImpl.stop()")
-	vm.purgeTicker.Stop()
+	if vm.purgeTicker != nil {
+		vm.purgeTicker.Stop()
+	}
+	if vm.memoryCheckTicker != nil {
+		vm.memoryCheckTicker.Stop()
+	}
 	if len(vm.stopChan) == 0 {
 		vm.stopChan <- struct{}{}
 	}
@@ -75,18 +131,13 @@ func (vm *VisitorManagerImpl) stop() {
 }
 
 func (vm *VisitorManagerImpl) GetVisitor(visitorCode string) Visitor {
-	// It is essential to update a visitor's last activity time before the visitor can be removed.
-	// However, the used map type `cmap.ConcurrentMap` does not provide a "tryGet" method
-	// with callback support. That is the reason why `RemoveCb` method is used as a "tryGet".
+	// It is essential to update a visitor's last activity time before the visitor can be removed,
+	// the store does it atomically with the lookup.
 	logging.Debug("CALL: VisitorManagerImpl.GetVisitor(visitorCode: %s)", visitorCode)
 	var visitor Visitor
-	vm.visitors.RemoveCb(visitorCode, func(vc string, v *VisitorImpl, exists bool) bool {
-		if v != nil {
-			v.UpdateLastActivityTime()
-			visitor = v
-		}
-		return false
-	})
+	if v := vm.visitors.Get(visitorCode); v != nil {
+		visitor = v
+	}
 	logging.Debug("RETURN: VisitorManagerImpl.GetVisitor(visitorCode: %s) -> (visitor: %s)",
 		visitorCode, visitor)
 	return visitor
@@ -97,13 +148,18 @@ func (vm *VisitorManagerImpl) GetOrCreateVisitor(visitorCode string) Visitor {
 }
 func (vm *VisitorManagerImpl) getOrCreateVisitor(visitorCode string) *VisitorImpl {
 	logging.Debug("CALL: VisitorManagerImpl.getOrCreateVisitor(visitorCode: %s)", visitorCode)
-	visitor := vm.visitors.Upsert(visitorCode, nil, func(exist bool, former, _ *VisitorImpl) *VisitorImpl {
+	created := false
+	visitor := vm.visitors.Upsert(visitorCode, func(former *VisitorImpl) *VisitorImpl {
 		if former != nil {
 			former.UpdateLastActivityTime()
 			return former
 		}
+		created = true
 		return NewVisitorImpl()
 	})
+	if created {
+		vm.onVisitorCreated()
+	}
 	logging.Debug("RETURN: VisitorManagerImpl.getOrCreateVisitor(visitorCode: %s) -> (visitor)", visitorCode)
 	return visitor
 }
@@ -132,10 +188,17 @@ func (vm *VisitorManagerImpl) AddDataWithTrack(visitorCode string, track bool, d
 		}
 	}
 	visitor.AddData(data...)
+	vm.visitors.Update(visitorCode, visitor)
 	logging.Debug("RETURN: VisitorManagerImpl.AddDataWithTrack(visitorCode: %s, track: %t, data: %s) -> (visitor)", visitorCode, track, data)
 	return visitor
 }
 
+func (vm *VisitorManagerImpl) Persist(visitorCode string, visitor Visitor) {
+	if v, ok := visitor.(*VisitorImpl); ok && (v != nil) {
+		vm.visitors.Update(visitorCode, v)
+	}
+}
+
 func (vm *VisitorManagerImpl) processCustomData(
 	visitorCode string,
 	visitor *VisitorImpl,
@@ -160,6 +223,7 @@ func (vm *VisitorManagerImpl) processCustomData(
 		userId := cd.Values()[0]
 		if visitorCode != userId {
 			vm.visitors.Set(userId, cloneVisitorImpl(visitor))
+			vm.onVisitorCreated()
 			logging.Info("Linked anonymous visitor '%s' with user '%s'", visitorCode, userId)
 		}
 		return types.NewMappingIdentifier(cd)
@@ -201,14 +265,12 @@ func isMappingIdentifier(cdi *types.CustomDataInfo, cd types.ICustomData) bool {
 }
 
 func (vm *VisitorManagerImpl) Enumerate(f func(string, Visitor) bool) {
-	for kv := range vm.visitors.IterBuffered() {
-		if !f(kv.Key, kv.Val) {
-			return
-		}
-	}
+	vm.visitors.Enumerate(func(vc string, v *VisitorImpl) bool {
+		return f(vc, v)
+	})
 }
 func (vm *VisitorManagerImpl) Len() int {
-	return vm.visitors.Count()
+	return vm.visitors.Len()
 }
 
 func (vm *VisitorManagerImpl) purge() {
@@ -218,16 +280,17 @@ func (vm *VisitorManagerImpl) purge() {
 		vc string
 		v  *VisitorImpl
 	}
-	vm.visitors.IterCb(func(vc string, v *VisitorImpl) {
+	vm.visitors.Enumerate(func(vc string, v *VisitorImpl) bool {
 		if v.LastActivityTime().Before(expiredDT) {
 			vrs = append(vrs, struct {
 				vc string
 				v  *VisitorImpl
 			}{vc: vc, v: v})
 		}
+		return true
 	})
 	for _, vr := range vrs {
-		vm.visitors.RemoveCb(vr.vc, func(key string, v *VisitorImpl, exists bool) bool {
+		vm.visitors.RemoveIf(vr.vc, func(v *VisitorImpl) bool {
 			return v.LastActivityTime().Before(expiredDT)
 		})
 	}
@@ -0,0 +1,481 @@
+package storage
+
+import (
+	"math"
+	"time"
+
+	"github.com/Kameleoon/client-go/v3/logging"
+	"github.com/Kameleoon/client-go/v3/types"
+	"github.com/segmentio/encoding/json"
+)
+
+// VisitorSnapshot is a serializable representation of a visitor.
+//
+// The sent/unsent state and the nonce of every sendable data are kept, so a restored visitor
+// neither resends already tracked data nor loses the data which is still to be tracked.
+// Simulated variations are not kept because they are read from the request cookie every time.
+type VisitorSnapshot struct {
+	TimeStarted        int64                     `json:"timeStarted"`
+	LastActivityTime   int64                     `json:"lastActivityTime"`
+	IsUniqueIdentifier bool                      `json:"isUniqueIdentifier,omitempty"`
+	MappingIdentifier  *string                   `json:"mappingIdentifier,omitempty"`
+	UserAgent          string                    `json:"userAgent,omitempty"`
+	LegalConsent       types.LegalConsent        `json:"legalConsent,omitempty"`
+	Device             *deviceSnapshot           `json:"device,omitempty"`
+	Browser            *browserSnapshot          `json:"browser,omitempty"`
+	OperatingSystem    *operatingSystemSnapshot  `json:"operatingSystem,omitempty"`
+	Geolocation        *geolocationSnapshot      `json:"geolocation,omitempty"`
+	VisitorVisits      *visitorVisitsSnapshot    `json:"visitorVisits,omitempty"`
+	KcsHeat            map[int]map[int]float64   `json:"kcsHeat,omitempty"`
+	CBScores           map[int][][]int           `json:"cbscores,omitempty"`
+	Cookie             map[string]string         `json:"cookie,omitempty"`
+	ApplicationVersion *string                   `json:"applicationVersion,omitempty"`
+	CustomData         []customDataSnapshot      `json:"customData,omitempty"`
+	PageViewVisits     []pageViewVisitSnapshot   `json:"pageViewVisits,omitempty"`
+	Conversions        []conversionSnapshot      `json:"conversions,omitempty"`
+	Variations         []variationSnapshot       `json:"variations,omitempty"`
+	Personalizations   []personalizationSnapshot `json:"personalizations,omitempty"`
+	TargetedSegments   []targetedSegmentSnapshot `json:"targetedSegments,omitempty"`
+	ForcedVariations   []forcedVariationSnapshot `json:"forcedVariations,omitempty"`
+}
+
+type sendableSnapshot struct {
+	Nonce string              `json:"nonce,omitempty"`
+	State types.SendableState `json:"state,omitempty"`
+}
+
+func newSendableSnapshot(s types.PersistableSendable) sendableSnapshot {
+	nonce, state := s.PersistedState()
+	return sendableSnapshot{Nonce: nonce, State: state}
+}
+
+func (ss sendableSnapshot) restore(s types.PersistableSendable) {
+	s.RestorePersistedState(ss.Nonce, ss.State)
+}
+
+type deviceSnapshot struct {
+	sendableSnapshot
+	Type types.DeviceType `json:"type"`
+}
+
+type browserSnapshot struct {
+	sendableSnapshot
+	Type    types.BrowserType `json:"type"`
+	Version float32           `json:"version,omitempty"`
+}
+
+type operatingSystemSnapshot struct {
+	sendableSnapshot
+	Type types.OperatingSystemType `json:"type"`
+}
+
+type geolocationSnapshot struct {
+	sendableSnapshot
+	Country    string `json:"country"`
+	Region     string `json:"region,omitempty"`
+	City       string `json:"city,omitempty"`
+	PostalCode string `json:"postalCode,omitempty"`
+	// Coordinates are NaN when unknown, JSON can't represent it
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+}
+
+type visitorVisitsSnapshot struct {
+	sendableSnapshot
+	VisitNumber int             `json:"visitNumber"`
+	PrevVisits  []visitSnapshot `json:"prevVisits,omitempty"`
+}
+
+type visitSnapshot struct {
+	TimeStarted      int64 `json:"timeStarted"`
+	TimeLastActivity int64 `json:"timeLastActivity"`
+}
+
+type customDataSnapshot struct {
+	sendableSnapshot
+	Index             int      `json:"index"`
+	Name              string   `json:"name,omitempty"`
+	Values            []string `json:"values"`
+	Overwrite         bool     `json:"overwrite"`
+	MappingIdentifier bool     `json:"mappingIdentifier,omitempty"`
+}
+
+type pageViewVisitSnapshot struct {
+	sendableSnapshot
+	URL           string `json:"url"`
+	Title         string `json:"title,omitempty"`
+	Referrers     []int  `json:"referrers,omitempty"`
+	Count         int    `json:"count"`
+	LastTimestamp int64  `json:"lastTimestamp"`
+}
+
+type conversionSnapshot struct {
+	sendableSnapshot
+	GoalId   int                  `json:"goalId"`
+	Revenue  float64              `json:"revenue,omitempty"`
+	Negative bool                 `json:"negative,omitempty"`
+	Metadata []customDataSnapshot `json:"metadata,omitempty"`
+}
+
+type variationSnapshot struct {
+	sendableSnapshot
+	ExperimentId   int            `json:"experimentId"`
+	VariationId    int            `json:"variationId"`
+	RuleType       types.RuleType `json:"ruleType"`
+	AssignmentTime int64          `json:"assignmentTime"`
+}
+
+type personalizationSnapshot struct {
+	Id          int `json:"id"`
+	VariationId int `json:"variationId"`
+}
+
+type targetedSegmentSnapshot struct {
+	sendableSnapshot
+	Id int `json:"id"`
+}
+
+type forcedVariationSnapshot struct {
+	ExperimentId   int  `json:"experimentId"`
+	VariationId    *int `json:"variationId"`
+	ForceTargeting bool `json:"forceTargeting"`
+}
+
+// Snapshot returns the serializable state of the visitor.
+func (v *VisitorImpl) Snapshot() VisitorSnapshot {
+	vd := v.data
+	vd.mx.RLock()
+	defer vd.mx.RUnlock()
+	s := VisitorSnapshot{
+		TimeStarted:        vd.timeStarted.UnixMilli(),
+		LastActivityTime:   vd.lastActivityTime.UnixMilli(),
+		IsUniqueIdentifier: v.isUniqueIdentifier,
+		MappingIdentifier:  vd.mappingIdentifier,
+		UserAgent:          vd.userAgent,
+		LegalConsent:       vd.legalConsent,
+	}
+	if d := vd.device; d != nil {
+		s.Device = &deviceSnapshot{sendableSnapshot: newSendableSnapshot(d), Type: d.Type()}
+	}
+	if b := vd.browser; b != nil {
+		s.Browser = &browserSnapshot{sendableSnapshot: newSendableSnapshot(b), Type: b.Type(), Version: b.Version()}
+	}
+	if os := vd.operatingSystem; os != nil {
+		s.OperatingSystem = &operatingSystemSnapshot{sendableSnapshot: newSendableSnapshot(os), Type: os.Type()}
+	}
+	if g := vd.geolocation; g != nil {
+		s.Geolocation = newGeolocationSnapshot(g)
+	}
+	if vv := vd.visitorVisits; vv != nil {
+		s.VisitorVisits = newVisitorVisitsSnapshot(vv)
+	}
+	if vd.kcsHeat != nil {
+		s.KcsHeat = vd.kcsHeat.Values()
+	}
+	if vd.cbscores != nil {
+		s.CBScores = make(map[int][][]int, len(vd.cbscores.Values()))
+		for key, groups := range vd.cbscores.Values() {
+			ids := make([][]int, len(groups))
+			for i, group := range groups {
+				ids[i] = group.Ids()
+			}
+			s.CBScores[key] = ids
+		}
+	}
+	if vd.cookie != nil {
+		s.Cookie = vd.cookie.Cookies()
+	}
+	if vd.applicationVersion != nil {
+		s.ApplicationVersion = &vd.applicationVersion.Version
+	}
+	for _, cd := range vd.customDataMap {
+		s.CustomData = append(s.CustomData, newCustomDataSnapshot(cd))
+	}
+	for _, pvv := range vd.pageViewVisits {
+		s.PageViewVisits = append(s.PageViewVisits, newPageViewVisitSnapshot(pvv))
+	}
+	for _, c := range vd.conversions {
+		s.Conversions = append(s.Conversions, newConversionSnapshot(c))
+	}
+	for _, av := range vd.variations {
+		s.Variations = append(s.Variations, variationSnapshot{
+			sendableSnapshot: newSendableSnapshot(av),
+			ExperimentId:     av.ExperimentId(),
+			VariationId:      av.VariationId(),
+			RuleType:         av.RuleType(),
+			AssignmentTime:   av.AssignmentTime().UnixMilli(),
+		})
+	}
+	for _, p := range vd.personalizations {
+		s.Personalizations = append(s.Personalizations, personalizationSnapshot{
+			Id: p.Id(), VariationId: p.VariationId(),
+		})
+	}
+	for _, ts := range vd.targetedSegments {
+		s.TargetedSegments = append(s.TargetedSegments, targetedSegmentSnapshot{
+			sendableSnapshot: newSendableSnapshot(ts), Id: ts.Id(),
+		})
+	}
+	for experimentId, fev := range vd.forcedVariations {
+		fvs := forcedVariationSnapshot{ExperimentId: experimentId, ForceTargeting: fev.ForceTargeting()}
+		if varByExp := fev.VarByExp(); varByExp != nil {
+			fvs.VariationId = varByExp.VariationID
+		}
+		s.ForcedVariations = append(s.ForcedVariations, fvs)
+	}
+	return s
+}
+
+func newGeolocationSnapshot(g *types.Geolocation) *geolocationSnapshot {
+	gs := &geolocationSnapshot{
+		sendableSnapshot: newSendableSnapshot(g),
+		Country:          g.Country(),
+		Region:           g.Region(),
+		City:             g.City(),
+		PostalCode:       g.PostalCode(),
+	}
+	if !(math.IsNaN(g.Latitude()) || math.IsNaN(g.Longitude())) {
+		latitude, longitude := g.Latitude(), g.Longitude()
+		gs.Latitude, gs.Longitude = &latitude, &longitude
+	}
+	return gs
+}
+
+func newVisitorVisitsSnapshot(vv *types.VisitorVisits) *visitorVisitsSnapshot {
+	vvs := &visitorVisitsSnapshot{sendableSnapshot: newSendableSnapshot(vv), VisitNumber: vv.VisitNumber()}
+	for _, visit := range vv.PrevVisits() {
+		vvs.PrevVisits = append(vvs.PrevVisits, visitSnapshot{
+			TimeStarted: visit.TimeStarted(), TimeLastActivity: visit.TimeLastActivity(),
+		})
+	}
+	return vvs
+}
+
+func newCustomDataSnapshot(cd types.ICustomData) customDataSnapshot {
+	cds := customDataSnapshot{
+		Index:     cd.Index(),
+		Name:      cd.Name(),
+		Values:    cd.Values(),
+		Overwrite: cd.Overwrite(),
+	}
+	switch cd := cd.(type) {
+	case *types.MappingIdentifier:
+		cds.MappingIdentifier = true
+		cds.sendableSnapshot = newSendableSnapshot(&cd.CustomData)
+	case types.PersistableSendable:
+		cds.sendableSnapshot = newSendableSnapshot(cd)
+	}
+	return cds
+}
+
+func newPageViewVisitSnapshot(pvv types.PageViewVisit) pageViewVisitSnapshot {
+	pv := pvv.PageView
+	return pageViewVisitSnapshot{
+		sendableSnapshot: newSendableSnapshot(pv),
+		URL:              pv.URL(),
+		Title:            pv.Title(),
+		Referrers:        pv.Referrers(),
+		Count:            pvv.Count,
+		LastTimestamp:    pvv.LastTimestamp,
+	}
+}
+
+func newConversionSnapshot(c *types.Conversion) conversionSnapshot {
+	cs := conversionSnapshot{
+		sendableSnapshot: newSendableSnapshot(c),
+		GoalId:           c.GoalId(),
+		Revenue:          c.Revenue(),
+		Negative:         c.Negative(),
+	}
+	for _, cd := range c.Metadata() {
+		cs.Metadata = append(cs.Metadata, newCustomDataSnapshot(cd))
+	}
+	return cs
+}
+
+// NewVisitorImplFromSnapshot restores a visitor from its snapshot.
+// The data file is used to resolve forced variations; those which no longer exist in it are dropped.
+func NewVisitorImplFromSnapshot(s VisitorSnapshot, dataFile types.IDataFile) *VisitorImpl {
+	vd := &visitorData{
+		timeStarted:       time.UnixMilli(s.TimeStarted),
+		lastActivityTime:  time.UnixMilli(s.LastActivityTime),
+		mappingIdentifier: s.MappingIdentifier,
+		userAgent:         s.UserAgent,
+		legalConsent:      s.LegalConsent,
+	}
+	if s.Device != nil {
+		vd.device = types.NewDevice(s.Device.Type)
+		s.Device.restore(vd.device)
+	}
+	if s.Browser != nil {
+		vd.browser = types.NewBrowser(s.Browser.Type, s.Browser.Version)
+		s.Browser.restore(vd.browser)
+	}
+	if s.OperatingSystem != nil {
+		vd.operatingSystem = types.NewOperatingSystem(s.OperatingSystem.Type)
+		s.OperatingSystem.restore(vd.operatingSystem)
+	}
+	if s.Geolocation != nil {
+		vd.geolocation = s.Geolocation.toGeolocation()
+	}
+	if s.VisitorVisits != nil {
+		vd.visitorVisits = s.VisitorVisits.toVisitorVisits(s.TimeStarted)
+	}
+	if s.KcsHeat != nil {
+		vd.kcsHeat = types.NewKcsHeat(s.KcsHeat)
+	}
+	if s.CBScores != nil {
+		vd.cbscores = newCBScoresFromSnapshot(s.CBScores)
+	}
+	if s.Cookie != nil {
+		vd.cookie = types.NewCookie(s.Cookie)
+	}
+	if s.ApplicationVersion != nil {
+		vd.applicationVersion = types.NewApplicationVersion(*s.ApplicationVersion)
+	}
+	if len(s.CustomData) > 0 {
+		vd.customDataMap = make(map[int]types.ICustomData, len(s.CustomData))
+		for _, cds := range s.CustomData {
+			vd.customDataMap[cds.Index] = cds.toCustomData()
+		}
+	}
+	if len(s.PageViewVisits) > 0 {
+		vd.pageViewVisits = make(map[string]types.PageViewVisit, len(s.PageViewVisits))
+		for _, pvvs := range s.PageViewVisits {
+			pv := types.NewPageViewWithTitle(pvvs.URL, pvvs.Title, pvvs.Referrers...)
+			pvvs.restore(pv)
+			vd.pageViewVisits[pvvs.URL] = types.NewPageViewVisit(pv, pvvs.Count, pvvs.LastTimestamp)
+		}
+	}
+	if len(s.Conversions) > 0 {
+		vd.conversions = make([]*types.Conversion, 0, len(s.Conversions))
+		for _, cs := range s.Conversions {
+			vd.conversions = append(vd.conversions, cs.toConversion())
+		}
+	}
+	if len(s.Variations) > 0 {
+		vd.variations = make(map[int]*types.AssignedVariation, len(s.Variations))
+		for _, vs := range s.Variations {
+			av := types.NewAssignedVariationWithTime(
+				vs.ExperimentId, vs.VariationId, vs.RuleType, time.UnixMilli(vs.AssignmentTime),
+			)
+			vs.restore(av)
+			vd.variations[vs.ExperimentId] = av
+		}
+	}
+	if len(s.Personalizations) > 0 {
+		vd.personalizations = make(map[int]*types.Personalization, len(s.Personalizations))
+		for _, ps := range s.Personalizations {
+			vd.personalizations[ps.Id] = types.NewPersonalization(ps.Id, ps.VariationId)
+		}
+	}
+	if len(s.TargetedSegments) > 0 {
+		vd.targetedSegments = make(map[int]*types.TargetedSegment, len(s.TargetedSegments))
+		for _, tss := range s.TargetedSegments {
+			ts := types.NewTargetedSegment(tss.Id)
+			tss.restore(ts)
+			vd.targetedSegments[tss.Id] = ts
+		}
+	}
+	if (len(s.ForcedVariations) > 0) && (dataFile != nil) {
+		for _, fvs := range s.ForcedVariations {
+			if fev := fvs.toForcedVariation(dataFile); fev != nil {
+				if vd.forcedVariations == nil {
+					vd.forcedVariations = make(map[int]*types.ForcedExperimentVariation, len(s.ForcedVariations))
+				}
+				vd.forcedVariations[fvs.ExperimentId] = fev
+			}
+		}
+	}
+	return &VisitorImpl{data: vd, isUniqueIdentifier: s.IsUniqueIdentifier}
+}
+
+func (gs *geolocationSnapshot) toGeolocation() *types.Geolocation {
+	var g *types.Geolocation
+	if (gs.Latitude != nil) && (gs.Longitude != nil) {
+		g = types.NewGeolocationWithCoords(*gs.Latitude, *gs.Longitude, gs.Country, gs.Region, gs.City, gs.PostalCode)
+	} else {
+		g = types.NewGeolocation(gs.Country, gs.Region, gs.City, gs.PostalCode)
+	}
+	gs.restore(g)
+	return g
+}
+
+func (vvs *visitorVisitsSnapshot) toVisitorVisits(timeStarted int64) *types.VisitorVisits {
+	prevVisits := make([]types.Visit, 0, len(vvs.PrevVisits))
+	for _, visit := range vvs.PrevVisits {
+		prevVisits = append(prevVisits, types.NewVisit(visit.TimeStarted, visit.TimeLastActivity))
+	}
+	vv := types.NewVisitorVisits(prevVisits, vvs.VisitNumber).Localize(timeStarted)
+	vvs.restore(vv)
+	return vv
+}
+
+func newCBScoresFromSnapshot(values map[int][][]int) *types.CBScores {
+	// Groups are ordered by descending score, the original scores are irrelevant
+	cbsMap := make(map[int][]types.ScoredVarId, len(values))
+	for key, groups := range values {
+		var scores []types.ScoredVarId
+		for i, ids := range groups {
+			for _, id := range ids {
+				scores = append(scores, types.ScoredVarId{VariationId: id, Score: float64(len(groups) - i)})
+			}
+		}
+		cbsMap[key] = scores
+	}
+	return types.NewCBScores(cbsMap)
+}
+
+func (cds customDataSnapshot) toCustomData() types.ICustomData {
+	params := types.NewCustomDataOptParams().Overwrite(cds.Overwrite)
+	cd := types.NewNamedCustomDataWithOptParams(cds.Name, params, cds.Values...).NamedToIndexed(cds.Index)
+	cds.restore(cd)
+	if cds.MappingIdentifier {
+		return types.NewMappingIdentifier(cd)
+	}
+	return cd
+}
+
+func (cs conversionSnapshot) toConversion() *types.Conversion {
+	params := types.ConversionOptParams{Revenue: cs.Revenue, Negative: cs.Negative}
+	for _, mcds := range cs.Metadata {
+		if mcd, ok := mcds.toCustomData().(*types.CustomData); ok {
+			params.Metadata = append(params.Metadata, mcd)
+		}
+	}
+	c := types.NewConversionWithOptParams(cs.GoalId, params)
+	cs.restore(c)
+	return c
+}
+
+func (fvs forcedVariationSnapshot) toForcedVariation(dataFile types.IDataFile) *types.ForcedExperimentVariation {
+	ruleInfo, ok := dataFile.GetRuleInfoByExpId(fvs.ExperimentId)
+	if !ok {
+		logging.Info("Forced variation for experiment %s is dropped: the experiment no longer exists",
+			fvs.ExperimentId)
+		return nil
+	}
+	var varByExp *types.VariationByExposition
+	if fvs.VariationId != nil {
+		if varByExp = dataFile.GetVariation(*fvs.VariationId); varByExp == nil {
+			logging.Info("Forced variation for experiment %s is dropped: variation %s no longer exists",
+				fvs.ExperimentId, *fvs.VariationId)
+			return nil
+		}
+	}
+	return types.NewForcedExperimentVariation(ruleInfo.Rule, varByExp, fvs.ForceTargeting)
+}
+
+// MarshalVisitor serializes the visitor's snapshot to JSON.
+func MarshalVisitor(v *VisitorImpl) ([]byte, error) {
+	return json.Marshal(v.Snapshot())
+}
+
+// UnmarshalVisitor restores a visitor serialized with MarshalVisitor.
+func UnmarshalVisitor(data []byte, dataFile types.IDataFile) (*VisitorImpl, error) {
+	var s VisitorSnapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	return NewVisitorImplFromSnapshot(s, dataFile), nil
+}
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	cmap "github.com/orcaman/concurrent-map/v2"
+)
+
+// VisitorStore is the storage of visitors used by VisitorManagerImpl.
+//
+// Visitors are mutated in place after they are returned by the store, and Update is called after every change.
+// A store backed by a shared cache (Redis, memcached, etc.) writes the visitor back in Update with
+// VisitorImpl.Snapshot, while visitors missing locally can be restored with NewVisitorImplFromSnapshot.
+//
+// Implementations must be safe for concurrent use.
+type VisitorStore interface {
+	// Get returns the visitor or nil if there is no such visitor. The visitor's last activity time
+	// must be updated atomically with the lookup, so the visitor can't be removed in between by RemoveIf.
+	Get(visitorCode string) *VisitorImpl
+	// Upsert atomically replaces the visitor with the result of the callback and returns it.
+	// The former visitor is nil if there was none.
+	Upsert(visitorCode string, f func(former *VisitorImpl) *VisitorImpl) *VisitorImpl
+	Set(visitorCode string, visitor *VisitorImpl)
+	// Update is called after the visitor returned by the store is changed in place.
+	Update(visitorCode string, visitor *VisitorImpl)
+	// RemoveIf atomically removes the visitor if the predicate is true for it.
+	RemoveIf(visitorCode string, predicate func(visitor *VisitorImpl) bool)
+	// Enumerate calls the callback for every stored visitor until the callback returns false.
+	Enumerate(f func(visitorCode string, visitor *VisitorImpl) bool)
+	Len() int
+	Clear()
+}
+
+// InMemoryVisitorStore is the default process-local VisitorStore.
+type InMemoryVisitorStore struct {
+	visitors cmap.ConcurrentMap[string, *VisitorImpl]
+}
+
+func NewInMemoryVisitorStore() *InMemoryVisitorStore {
+	return &InMemoryVisitorStore{visitors: cmap.New[*VisitorImpl]()}
+}
+
+func (s *InMemoryVisitorStore) Get(visitorCode string) *VisitorImpl {
+	// `cmap.ConcurrentMap` does not provide a "tryGet" method with callback support.
+	// That is the reason why `RemoveCb` method is used as a "tryGet".
+	var visitor *VisitorImpl
+	s.visitors.RemoveCb(visitorCode, func(vc string, v *VisitorImpl, exists bool) bool {
+		if v != nil {
+			v.UpdateLastActivityTime()
+			visitor = v
+		}
+		return false
+	})
+	return visitor
+}
+
+func (s *InMemoryVisitorStore) Upsert(visitorCode string, f func(former *VisitorImpl) *VisitorImpl) *VisitorImpl {
+	return s.visitors.Upsert(visitorCode, nil, func(exist bool, former, _ *VisitorImpl) *VisitorImpl {
+		return f(former)
+	})
+}
+
+func (s *InMemoryVisitorStore) Set(visitorCode string, visitor *VisitorImpl) {
+	s.visitors.Set(visitorCode, visitor)
+}
+
+// Update does nothing, the stored visitors are the ones changed in place.
+func (s *InMemoryVisitorStore) Update(visitorCode string, visitor *VisitorImpl) {}
+
+func (s *InMemoryVisitorStore) RemoveIf(visitorCode string, predicate func(visitor *VisitorImpl) bool) {
+	s.visitors.RemoveCb(visitorCode, func(key string, v *VisitorImpl, exists bool) bool {
+		return exists && predicate(v)
+	})
+}
+
+func (s *InMemoryVisitorStore) Enumerate(f func(visitorCode string, visitor *VisitorImpl) bool) {
+	for kv := range s.visitors.IterBuffered() {
+		if !f(kv.Key, kv.Val) {
+			return
+		}
+	}
+}
+
+func (s *InMemoryVisitorStore) Len() int {
+	return s.visitors.Count()
+}
+
+func (s *InMemoryVisitorStore) Clear() {
+	s.visitors.Clear()
+}
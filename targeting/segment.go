@@ -42,6 +42,19 @@ func (s *Segment) CheckTargeting(data types.TargetingDataGetter) bool {
 	return s.Tree.CheckTargeting(data)
 }
 
+func (s *Segment) TraceTargeting(data types.TargetingDataGetter) *types.TargetingTrace {
+	if s == nil || s.Tree == nil {
+		trace := &types.TargetingTrace{Result: true}
+		if s != nil {
+			trace.SegmentId = s.ID
+		}
+		return trace
+	}
+	trace := s.Tree.TraceTargeting(data)
+	trace.SegmentId = s.ID
+	return trace
+}
+
 func (s *Segment) GetSegmentBase() *types.SegmentBase {
 	return &s.base
 }
@@ -13,6 +13,9 @@ import (
 
 type TargetingManager interface {
 	CheckTargeting(visitorCode string, campaignId int, segment types.Segment) bool
+	// TraceTargeting checks the targeting the same way as CheckTargeting and returns the evaluation trace.
+	// The result of the trace is true if there is no segment.
+	TraceTargeting(visitorCode string, campaignId int, segment types.Segment) *types.TargetingTrace
 }
 
 type targetingManager struct {
@@ -47,6 +50,31 @@ func (tm *targetingManager) CheckTargeting(
 	return targeted
 }
 
+func (tm *targetingManager) TraceTargeting(
+	visitorCode string,
+	campaignId int,
+	segment types.Segment,
+) *types.TargetingTrace {
+	logging.Debug(
+		"CALL: targetingManager.TraceTargeting(visitorCode: %s, campaignId: %s, segment: %s)",
+		visitorCode, campaignId, segment,
+	)
+	var trace *types.TargetingTrace
+	if segment == nil {
+		trace = &types.TargetingTrace{Result: true}
+	} else {
+		visitor := tm.visitorManager.GetVisitor(visitorCode)
+		trace = segment.TraceTargeting(func(targetingType types.TargetingType) interface{} {
+			return tm.getConditionData(targetingType, visitor, visitorCode, campaignId)
+		})
+	}
+	logging.Debug(
+		"RETURN: targetingManager.TraceTargeting(visitorCode: %s, campaignId: %s, segment: %s) -> (targeted: %s)",
+		visitorCode, campaignId, segment, trace.Result,
+	)
+	return trace
+}
+
 func (tm *targetingManager) getConditionData(
 	targetingType types.TargetingType,
 	visitor storage.Visitor,
@@ -84,6 +84,87 @@ func (t *Tree) checkCondition(data types.TargetingDataGetter) bool {
 	return targeted
 }
 
+// TraceTargeting checks the targeting the same way as CheckTargeting and records every node evaluated.
+func (t *Tree) TraceTargeting(data types.TargetingDataGetter) *types.TargetingTrace {
+	if t.Condition != nil {
+		return t.traceCondition(data)
+	}
+	trace := &types.TargetingTrace{Operator: types.TargetingTraceOperatorAnd}
+	if t.OrOperator {
+		trace.Operator = types.TargetingTraceOperatorOr
+	}
+	leftTargeted, rightTargeted := true, true
+	if t.LeftTree != nil {
+		leftTrace := t.LeftTree.TraceTargeting(data)
+		leftTargeted = leftTrace.Result
+		trace.Children = append(trace.Children, leftTrace)
+	}
+	if t.OrOperator && leftTargeted {
+		if t.RightTree != nil {
+			trace.Children = append(trace.Children, t.RightTree.skippedTrace())
+		}
+		trace.Result = true
+		return trace
+	}
+	if t.RightTree != nil {
+		rightTrace := t.RightTree.TraceTargeting(data)
+		rightTargeted = rightTrace.Result
+		trace.Children = append(trace.Children, rightTrace)
+	}
+	trace.Result = (t.OrOperator && rightTargeted) || (leftTargeted && rightTargeted)
+	return trace
+}
+
+func (t *Tree) traceCondition(data types.TargetingDataGetter) *types.TargetingTrace {
+	td := data(t.Condition.GetType())
+	targeted := t.Condition.CheckTargeting(td)
+	if !t.Condition.GetInclude() {
+		targeted = !targeted
+	}
+	return &types.TargetingTrace{
+		ConditionType: t.Condition.GetType(),
+		Condition:     t.Condition.String(),
+		Exclude:       !t.Condition.GetInclude(),
+		Data:          describeConditionData(td),
+		Result:        targeted,
+	}
+}
+
+// skippedTrace records the structure of a branch which was not evaluated.
+func (t *Tree) skippedTrace() *types.TargetingTrace {
+	trace := &types.TargetingTrace{Skipped: true}
+	if t.Condition != nil {
+		trace.ConditionType = t.Condition.GetType()
+		trace.Condition = t.Condition.String()
+		trace.Exclude = !t.Condition.GetInclude()
+		return trace
+	}
+	trace.Operator = types.TargetingTraceOperatorAnd
+	if t.OrOperator {
+		trace.Operator = types.TargetingTraceOperatorOr
+	}
+	for _, child := range []*Tree{t.LeftTree, t.RightTree} {
+		if child != nil {
+			trace.Children = append(trace.Children, child.skippedTrace())
+		}
+	}
+	return trace
+}
+
+func describeConditionData(data interface{}) string {
+	switch td := data.(type) {
+	case nil:
+		return ""
+	case conditions.TargetingDataSegmentCondition:
+		// The nested segment is evaluated by the condition itself
+		return ""
+	case conditions.TargetingDataTargetFeatureFlagCondition:
+		// The data file is not worth printing
+		return logging.ObjectToString(td.VariationStorage)
+	}
+	return logging.ObjectToString(data)
+}
+
 func NewTree(cd *types.ConditionsData) *Tree {
 	return createFirstLevel(cd)
 }
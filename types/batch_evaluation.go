@@ -0,0 +1,21 @@
+package types
+
+// BatchEvaluationResult is the variation matrix calculated by the batch evaluation.
+type BatchEvaluationResult struct {
+	VisitorCodes []string
+	FeatureKeys  []string
+	// VariationKeys[i][j] is the variation key of FeatureKeys[j] for VisitorCodes[i].
+	// It is empty if the evaluation failed, the row is nil if the visitor was not evaluated.
+	VariationKeys [][]string
+	// Errors[i] is the first error of the evaluation for VisitorCodes[i] or nil
+	Errors []error
+}
+
+// BatchVisitorResult is the row of the batch evaluation for a single visitor.
+type BatchVisitorResult struct {
+	// Index is the index of the visitor code in the evaluated visitor codes
+	Index         int
+	VisitorCode   string
+	VariationKeys []string
+	Err           error
+}
@@ -1,5 +1,7 @@
 package types
 
+import "strings"
+
 type RuleType uint8
 
 const (
@@ -26,6 +28,16 @@ func (rt *RuleType) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+func (rt RuleType) String() string {
+	switch rt {
+	case RuleTypeExperimentation:
+		return strings.Trim(ruleTypeLiteralExperimentation, `"`)
+	case RuleTypeTargetedDelivery:
+		return strings.Trim(ruleTypeLiteralTargetedDelivery, `"`)
+	}
+	return "UNKNOWN"
+}
+
 type RuleBase struct {
 	Experiment
 	Order       int      `json:"order"`
@@ -2,5 +2,13 @@ package types
 
 type Segment interface {
 	CheckTargeting(data TargetingDataGetter) bool
+	// TraceTargeting checks the targeting the same way as CheckTargeting and records every node evaluated.
+	TraceTargeting(data TargetingDataGetter) *TargetingTrace
 	GetSegmentBase() *SegmentBase
 }
+
+// SegmentInfo identifies a segment which a visitor matches.
+type SegmentInfo struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+}
@@ -1,8 +1,8 @@
 package types
 
 type SegmentBase struct {
-	ID int `json:"id"`
-	// Name                     string          `json:"name"`
+	ID   int    `json:"id"`
+	Name string `json:"name"`
 	// Description              string          `json:"description"`
 	ConditionsData *ConditionsData `json:"conditionsData"`
 	// SiteID                   int             `json:"siteId"`
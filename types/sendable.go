@@ -17,6 +17,15 @@ type Sendable interface {
 	MarkAsSent()
 }
 
+// PersistableSendable is implemented by all the sendable data. It gives access to the sending state
+// so that the data can be persisted and restored without being sent twice or lost.
+type PersistableSendable interface {
+	Sendable
+
+	PersistedState() (nonce string, state SendableState)
+	RestorePersistedState(nonce string, state SendableState)
+}
+
 type SendableState byte
 
 const (
@@ -56,6 +65,20 @@ func (sb *sendableBase) MarkAsSent() {
 	sb.nonce = ""
 }
 
+func (sb *sendableBase) PersistedState() (string, SendableState) {
+	return sb.nonce, sb.state
+}
+
+// RestorePersistedState restores the state of a persisted data. The transmitting state is restored as unsent
+// as the result of the transmission can't be known.
+func (sb *sendableBase) RestorePersistedState(nonce string, state SendableState) {
+	if state == SendableStateTransmitting {
+		state = SendableStateUnsent
+	}
+	sb.nonce = nonce
+	sb.state = state
+}
+
 type duplicationSafeSendableBase struct {
 	sendableBase
 }
@@ -0,0 +1,26 @@
+package types
+
+type TargetingTraceOperator string
+
+const (
+	TargetingTraceOperatorAnd TargetingTraceOperator = "and"
+	TargetingTraceOperatorOr  TargetingTraceOperator = "or"
+)
+
+// TargetingTrace records the evaluation of a targeting tree node.
+//
+// An inner node has an operator and children, a leaf node has a condition. Skipped nodes were not evaluated
+// because the result of an "or" node was already known, their Result and Data are not set.
+type TargetingTrace struct {
+	// SegmentId is set for the root node of a segment
+	SegmentId     int                    `json:"segmentId,omitempty"`
+	Operator      TargetingTraceOperator `json:"operator,omitempty"`
+	ConditionType TargetingType          `json:"conditionType,omitempty"`
+	Condition     string                 `json:"condition,omitempty"`
+	// Exclude is set for an exclusion condition, whose result is the negated result of the condition
+	Exclude  bool              `json:"exclude,omitempty"`
+	Data     string            `json:"data,omitempty"`
+	Result   bool              `json:"result"`
+	Skipped  bool              `json:"skipped,omitempty"`
+	Children []*TargetingTrace `json:"children,omitempty"`
+}
@@ -0,0 +1,80 @@
+package types
+
+// VariationExplanation describes how the variation of a feature flag was chosen for a visitor.
+// The steps are listed in the order they were evaluated, the steps which were not reached are nil or empty.
+type VariationExplanation struct {
+	VisitorCode        string `json:"visitorCode"`
+	FeatureKey         string `json:"featureKey"`
+	VariationKey       string `json:"variationKey"`
+	EnvironmentEnabled bool   `json:"environmentEnabled"`
+	// BucketingKey is the code which the hashes are calculated for
+	BucketingKey       string                      `json:"bucketingKey"`
+	BucketingKeySource BucketingKeySource          `json:"bucketingKeySource"`
+	LegalConsent       string                      `json:"legalConsent"`
+	ConsentBlocking    string                      `json:"consentBlocking"`
+	ForcedVariation    *ForcedVariationExplanation `json:"forcedVariation,omitempty"`
+	Holdout            *HoldoutExplanation         `json:"holdout,omitempty"`
+	MEGroup            *MEGroupExplanation         `json:"meGroup,omitempty"`
+	Rules              []*RuleExplanation          `json:"rules,omitempty"`
+}
+
+type BucketingKeySource string
+
+const (
+	BucketingKeySourceVisitorCode       BucketingKeySource = "visitor_code"
+	BucketingKeySourceMappingIdentifier BucketingKeySource = "mapping_identifier"
+	BucketingKeySourceCustomData        BucketingKeySource = "custom_data"
+)
+
+// ForcedVariationExplanation describes a variation forced for the feature flag with SetForcedVariation.
+type ForcedVariationExplanation struct {
+	VariationKey string `json:"variationKey"`
+	Simulated    bool   `json:"simulated"`
+}
+
+type HoldoutExplanation struct {
+	ExperimentId int     `json:"experimentId"`
+	Hash         float64 `json:"hash"`
+	VariationKey string  `json:"variationKey"`
+	InHoldout    bool    `json:"inHoldout"`
+	// BlockedByConsent means the evaluation stopped at the holdout because the consent was not given
+	BlockedByConsent bool `json:"blockedByConsent"`
+}
+
+type MEGroupExplanation struct {
+	Name string  `json:"name"`
+	Hash float64 `json:"hash"`
+	// SelectedFeatureKey is the feature flag of the group which the hash falls into
+	SelectedFeatureKey string `json:"selectedFeatureKey"`
+	Unrestricted       bool   `json:"unrestricted"`
+}
+
+type RuleOutcome string
+
+const (
+	// RuleOutcomeForced means the variation of the rule's experiment was forced with SetForcedVariation
+	RuleOutcomeForced           RuleOutcome = "forced"
+	RuleOutcomeNotTargeted      RuleOutcome = "not_targeted"
+	RuleOutcomeNotExposed       RuleOutcome = "not_exposed"
+	RuleOutcomeBlockedByConsent RuleOutcome = "blocked_by_consent"
+	// RuleOutcomeCBScores means the variation was chosen by the contextual bandit scores
+	RuleOutcomeCBScores    RuleOutcome = "cb_scores"
+	RuleOutcomeAssigned    RuleOutcome = "assigned"
+	RuleOutcomeNoVariation RuleOutcome = "no_variation"
+)
+
+type RuleExplanation struct {
+	RuleId       int    `json:"ruleId"`
+	ExperimentId int    `json:"experimentId"`
+	Type         string `json:"type"`
+	SegmentId    int    `json:"segmentId,omitempty"`
+	// Targeted is the result of the rule's segment, it is true for a rule without a segment
+	Targeted bool `json:"targeted"`
+	// Targeting is the evaluation trace of the rule's segment
+	Targeting      *TargetingTrace `json:"targeting,omitempty"`
+	ExpositionHash *float64        `json:"expositionHash,omitempty"`
+	Exposition     float64         `json:"exposition"`
+	VariationHash  *float64        `json:"variationHash,omitempty"`
+	VariationKey   string          `json:"variationKey,omitempty"`
+	Outcome        RuleOutcome     `json:"outcome"`
+}
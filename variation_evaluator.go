@@ -0,0 +1,410 @@
+package kameleoon
+
+import (
+	"fmt"
+
+	"github.com/Kameleoon/client-go/v3/errs"
+	"github.com/Kameleoon/client-go/v3/logging"
+	"github.com/Kameleoon/client-go/v3/managers/data"
+	"github.com/Kameleoon/client-go/v3/storage"
+	"github.com/Kameleoon/client-go/v3/targeting"
+	"github.com/Kameleoon/client-go/v3/types"
+	"github.com/Kameleoon/client-go/v3/utils"
+)
+
+// variationEvaluator calculates the variations of the feature flags for the visitors of the visitor manager.
+// Nothing is tracked by the evaluator, it only saves the assigned variations to the visitors.
+type variationEvaluator struct {
+	dataManager      data.DataManager
+	visitorManager   storage.VisitorManager
+	targetingManager targeting.TargetingManager
+}
+
+// newVariationEvaluator returns an evaluator bound to the data file, even if the configuration is updated meanwhile.
+func newVariationEvaluator(dataFile types.IDataFile, visitorManager storage.VisitorManager) *variationEvaluator {
+	dm := data.NewDataManagerImpl(dataFile)
+	return newVariationEvaluatorWithManagers(dm, visitorManager, targeting.NewTargetingManager(dm, visitorManager))
+}
+
+func newVariationEvaluatorWithManagers(
+	dataManager data.DataManager, visitorManager storage.VisitorManager, targetingManager targeting.TargetingManager,
+) *variationEvaluator {
+	return &variationEvaluator{
+		dataManager:      dataManager,
+		visitorManager:   visitorManager,
+		targetingManager: targetingManager,
+	}
+}
+
+func (e *variationEvaluator) saveVariation(
+	visitorCode string, evalExp *evaluatedExperiment, track bool,
+) {
+	if (evalExp == nil) || (evalExp.experiment.ExperimentId == 0) || (evalExp.varByExp.VariationID == nil) {
+		return
+	}
+	logging.Debug(
+		"CALL: variationEvaluator.saveVariation(visitorCode: %s, evalExp: %s, track: %s)",
+		visitorCode, evalExp, track,
+	)
+	visitor := e.visitorManager.GetOrCreateVisitor(visitorCode)
+	asVariation := types.NewAssignedVariation(
+		evalExp.experiment.ExperimentId, *evalExp.varByExp.VariationID, evalExp.ruleType,
+	)
+	if !track {
+		asVariation.MarkAsSent()
+	}
+	visitor.AssignVariation(asVariation)
+	e.visitorManager.Persist(visitorCode, visitor)
+	logging.Debug(
+		"RETURN: variationEvaluator.saveVariation(visitorCode: %s, evalExp: %s, track: %s)",
+		visitorCode, evalExp, track,
+	)
+}
+
+func (e *variationEvaluator) calculateVariationKey(evalExp *evaluatedExperiment, defaultVariationKey string) string {
+	logging.Debug(
+		"CALL: variationEvaluator.calculateVariationKey(evalExp: %s, defaultVariationKey: %s)",
+		evalExp, defaultVariationKey,
+	)
+	var variationKey string
+	if evalExp != nil {
+		variationKey = evalExp.varByExp.VariationKey
+	} else {
+		variationKey = defaultVariationKey
+	}
+	logging.Debug(
+		"RETURN: variationEvaluator.calculateVariationKey(evalExp: %s, defaultVariationKey: %s) -> "+
+			"(variationKey: %s)", evalExp, defaultVariationKey, variationKey,
+	)
+	return variationKey
+}
+
+func (e *variationEvaluator) evaluateCBScores(
+	visitor storage.Visitor, visitorCode string, rule types.IRule, bucketingCustomDataIndex *int,
+) *evaluatedExperiment {
+	if (visitor == nil) || (visitor.CBScores() == nil) {
+		return nil
+	}
+	logging.Debug(
+		"CALL: variationEvaluator.evaluateCBScores(visitor, visitorCode: %s, rule: %s, bucketingCustomDataIndex: %s)",
+		visitorCode, rule, bucketingCustomDataIndex,
+	)
+	var evalExp *evaluatedExperiment
+	ruleVarsByExp := rule.GetRuleBase().VariationsByExposition
+	if varIdGroupByScores, ok := visitor.CBScores().Values()[rule.GetRuleBase().ExperimentId]; ok {
+		var varByExpInCbs []*types.VariationByExposition
+		for _, varGroup := range varIdGroupByScores {
+			// Finding varByExps which exist in CBS variation IDs
+			varByExpInCbs = make([]*types.VariationByExposition, 0, len(ruleVarsByExp))
+			for i := 0; i < len(ruleVarsByExp); i++ {
+				if (ruleVarsByExp[i].VariationID != nil) &&
+					utils.SliceContains(varGroup.Ids(), *ruleVarsByExp[i].VariationID) {
+					varByExpInCbs = append(varByExpInCbs, &ruleVarsByExp[i])
+				}
+			}
+			if len(varByExpInCbs) > 0 { // Skiping if not found any varByExp
+				break // We need take only one list with the highest scores
+			}
+		}
+		if len(varByExpInCbs) > 0 {
+			var idx int
+			if len(varByExpInCbs) > 1 { // if more than one varByExp for score -> randomly get
+				codeForHash := getCodeForHash(visitor, visitorCode, bucketingCustomDataIndex)
+				variationHash := utils.ObtainHashRule(
+					codeForHash, rule.GetRuleBase().ExperimentId, rule.GetRuleBase().RespoolTime,
+				)
+				logging.Debug("Calculated CBS hash %s for code %s", variationHash, codeForHash)
+				idx = int(variationHash * float64(len(varByExpInCbs)))
+				if idx >= len(varByExpInCbs) {
+					idx = len(varByExpInCbs) - 1
+				}
+			}
+			evalExp = newEvaluatedExperimentFromVarByExpRule(varByExpInCbs[idx], rule)
+		}
+	}
+	logging.Debug(
+		"RETURN: variationEvaluator.evaluateCBScores(visitor, visitorCode: %s, rule: %s, bucketingCustomDataIndex: %s)"+
+			" -> (evalExp: %s)", visitorCode, rule, bucketingCustomDataIndex, evalExp,
+	)
+	return evalExp
+}
+
+// getVariationRuleForFeature is a helper method for calculate variation key for feature flag
+func (e *variationEvaluator) calculateVariationRuleForFeature(
+	visitorCode string, featureFlag types.IFeatureFlag, explanation *types.VariationExplanation,
+) (evalExp *evaluatedExperiment, err error) {
+	logging.Debug(
+		"CALL: variationEvaluator.calculateVariationRuleForFeature(visitorCode: %s, featureFlag: %s)",
+		visitorCode, featureFlag,
+	)
+	defer func() {
+		logging.Debug(
+			"RETURN: variationEvaluator.calculateVariationRuleForFeature(visitorCode: %s, featureFlag: %s)"+
+				" -> (evalExp: %s, err: %s)",
+			visitorCode, featureFlag, evalExp, err,
+		)
+	}()
+	visitor := e.visitorManager.GetVisitor(visitorCode)
+	consent, blockingBehaviour := e.getConsentAndBlockingBehaviour(visitor)
+	codeForHash := getCodeForHash(visitor, visitorCode, featureFlag.GetBucketingCustomDataIndex())
+	// no rules -> return DefaultVariationKey
+	for _, rule := range featureFlag.GetRules() {
+		ruleExplanation := explainRule(explanation, rule)
+		var forcedVariation *types.ForcedExperimentVariation
+		if visitor != nil {
+			forcedVariation = visitor.GetForcedExperimentVariation(rule.GetRuleBase().ExperimentId)
+			if (forcedVariation != nil) && forcedVariation.ForceTargeting() {
+				// Forcing experiment variation in force-targeting mode
+				ruleExplanation.setOutcome(types.RuleOutcomeForced, forcedVariation.VarByExp())
+				return newEvaluatedExperimentFromVarByExpRule(forcedVariation.VarByExp(), rule), nil
+			}
+		}
+		// check if visitor is targeted for rule, else next rule
+		if !e.checkRuleTargeting(visitorCode, rule, ruleExplanation) {
+			ruleExplanation.setOutcome(types.RuleOutcomeNotTargeted, nil)
+			continue
+		}
+		if forcedVariation != nil {
+			// Forcing experiment variation in targeting-only mode
+			ruleExplanation.setOutcome(types.RuleOutcomeForced, forcedVariation.VarByExp())
+			return newEvaluatedExperimentFromVarByExpRule(forcedVariation.VarByExp(), rule), nil
+		}
+
+		// Disable searching in variation storage (uncommented if you need use variation storage)
+		// check for saved variation for rule if it's experimentation rule
+		// if savedVariation, found := e.getSavedVariationForRule(visitorCode, &rule); found {
+		// 	return savedVariation, &rule, false
+		// }
+
+		// used for rule exposition
+		hashRule := utils.ObtainHashRule(codeForHash, rule.GetRuleBase().Id, rule.GetRuleBase().RespoolTime)
+		logging.Debug("Calculated rule hash %s for code %s", hashRule, codeForHash)
+		ruleExplanation.setExpositionHash(hashRule)
+		// check main expostion for rule with hashRule
+		if hashRule <= rule.GetRuleBase().Exposition {
+			// Checking if the evaluation is blocked due to the consent policy
+			if (consent == types.LegalConsentNotGiven) && (rule.GetRuleBase().Type == types.RuleTypeExperimentation) {
+				ruleExplanation.setOutcome(types.RuleOutcomeBlockedByConsent, nil)
+				if blockingBehaviour == types.PartiallyBlockedByConsent {
+					return nil, nil
+				}
+				// TODO: In the next major, create a new error type for the Completely Blocked by Consent error
+				return nil, errs.NewFeatureEnvironmentDisabledWithMessage(fmt.Sprintf(
+					"Evaluation of %v is blocked because consent is not provided for visitor '%s'",
+					rule, visitorCode,
+				))
+			}
+			// check main exposition for rule with hashRule
+			evalExp = e.evaluateCBScores(visitor, visitorCode, rule, featureFlag.GetBucketingCustomDataIndex())
+			if evalExp != nil {
+				ruleExplanation.setOutcome(types.RuleOutcomeCBScores, evalExp.varByExp)
+				return
+			}
+			if rule.IsTargetDeliveryType() {
+				var variation *types.VariationByExposition
+				if len(rule.GetRuleBase().VariationsByExposition) > 0 {
+					variation = &rule.GetRuleBase().VariationsByExposition[0]
+				}
+				ruleExplanation.setOutcome(types.RuleOutcomeAssigned, variation)
+				return newEvaluatedExperimentFromVarByExpRule(variation, rule), nil
+			}
+			// used for variation's expositions
+			hashVariation := utils.ObtainHashRule(
+				codeForHash, rule.GetRuleBase().ExperimentId, rule.GetRuleBase().RespoolTime,
+			)
+			logging.Debug("Calculated variation hash %s for code %s", hashVariation, codeForHash)
+			ruleExplanation.setVariationHash(hashVariation)
+			// get variation with new hashVariation
+			variation := rule.GetVariationByHash(hashVariation)
+			if variation != nil {
+				ruleExplanation.setOutcome(types.RuleOutcomeAssigned, variation)
+				return newEvaluatedExperimentFromVarByExpRule(variation, rule), nil
+			}
+			ruleExplanation.setOutcome(types.RuleOutcomeNoVariation, nil)
+		} else {
+			ruleExplanation.setOutcome(types.RuleOutcomeNotExposed, nil)
+		}
+		if rule.IsTargetDeliveryType() {
+			break
+		}
+	}
+	return nil, nil
+}
+
+func (e *variationEvaluator) getConsentAndBlockingBehaviour(visitor storage.Visitor) (types.LegalConsent, types.ConsentBlockingBehaviour) {
+	dataFile := e.dataManager.DataFile()
+
+	consent := types.LegalConsentGiven
+	if dataFile.Settings().IsConsentRequired() {
+		consent = types.LegalConsentUnknown
+		if visitor != nil {
+			consent = visitor.LegalConsent()
+		}
+	}
+	behaviour := dataFile.Settings().BlockingBehaviourIfConsentNotGiven()
+
+	return consent, behaviour
+}
+
+func (e *variationEvaluator) getVariationInfo(
+	visitorCode string, featureFlag types.IFeatureFlag, track bool,
+) (variationKey string, evalExp *evaluatedExperiment, err error) {
+	logging.Debug(
+		"CALL: variationEvaluator.getVariationInfo(visitorCode: %s, featureFlag: %s, track: %s)",
+		visitorCode, featureFlag, track,
+	)
+	visitor := e.visitorManager.GetVisitor(visitorCode)
+	evalExp, err = e.evaluate(visitor, visitorCode, featureFlag, track, true, nil)
+	if err == nil {
+		defaultVariationKey := featureFlag.GetDefaultVariationKey()
+		variationKey = e.calculateVariationKey(evalExp, defaultVariationKey)
+	}
+	logging.Debug(
+		"RETURN: variationEvaluator.getVariationInfo(visitorCode: %s, featureFlag: %s, track: %s)"+
+			" -> (variationKey: %s, evalExp: %s, err: %s)", visitorCode, featureFlag, track, variationKey, evalExp, err,
+	)
+	return
+}
+
+// evaluate calculates the variation of the feature flag. The explanation (optional) is filled with the steps.
+func (e *variationEvaluator) evaluate(
+	visitor storage.Visitor, visitorCode string, featureFlag types.IFeatureFlag, track, save bool,
+	explanation *types.VariationExplanation,
+) (evalExp *evaluatedExperiment, err error) {
+	logging.Debug(
+		"CALL: variationEvaluator.evaluate(visitor, visitorCode: %s, featureFlag: %s, track: %s, save: %s)",
+		visitorCode, featureFlag, track, save,
+	)
+	defer func() {
+		logging.Debug(
+			"RETURN: variationEvaluator.evaluate(visitor, visitorCode: %s, featureFlag: %s, track: %s, save: %s)"+
+				" -> (evalExp: %s, err: %s)", visitorCode, featureFlag, track, save, evalExp, err,
+		)
+	}()
+	var forcedVariation *types.ForcedFeatureVariation
+	if visitor != nil {
+		forcedVariation = visitor.GetForcedFeatureVariation(featureFlag.GetFeatureKey())
+	}
+	if forcedVariation != nil {
+		evalExp = newEvaluatedExperimentFromForcedVariation(forcedVariation)
+		if explanation != nil {
+			explanation.ForcedVariation = &types.ForcedVariationExplanation{Simulated: forcedVariation.Simulated()}
+			if evalExp != nil {
+				explanation.ForcedVariation.VariationKey = evalExp.varByExp.VariationKey
+			}
+		}
+	} else {
+		var isVisitorNotInHoldout bool
+		isVisitorNotInHoldout, err = e.isVisitorNotInHoldout(
+			visitor, visitorCode, track, save, featureFlag.GetBucketingCustomDataIndex(), explanation,
+		)
+		if err != nil {
+			return
+		}
+		if isVisitorNotInHoldout && e.isFFUnrestrictedByMEGroup(visitor, visitorCode, featureFlag, explanation) {
+			if evalExp, err = e.calculateVariationRuleForFeature(visitorCode, featureFlag, explanation); err != nil {
+				return
+			}
+		}
+	}
+	if save && ((forcedVariation == nil) || !forcedVariation.Simulated()) {
+		e.saveVariation(visitorCode, evalExp, track)
+	}
+	return
+}
+
+func (e *variationEvaluator) isFFUnrestrictedByMEGroup(
+	visitor storage.Visitor, visitorCode string, featureFlag types.IFeatureFlag, explanation *types.VariationExplanation,
+) bool {
+	meGroupName := featureFlag.GetMEGroupName()
+	if meGroupName == "" {
+		return true
+	}
+	logging.Debug(
+		"CALL: variationEvaluator.isFFUnrestrictedByMEGroup(visitor, visitorCode: %s, featureFlag: %s)",
+		visitorCode, featureFlag,
+	)
+	unrestricted := true
+	if meGroup := e.dataManager.DataFile().MEGroups()[meGroupName]; meGroup != nil {
+		codeForHash := getCodeForHash(visitor, visitorCode, featureFlag.GetBucketingCustomDataIndex())
+		meGroupHash := utils.ObtainHashForMEGroup(codeForHash, meGroupName)
+		logging.Debug("Calculated ME group hash %s for code: %s, meGroup: %s", meGroupHash, codeForHash, meGroupName)
+		selectedFeatureFlag := meGroup.GetFeatureFlagByHash(meGroupHash)
+		unrestricted = selectedFeatureFlag == featureFlag
+		if explanation != nil {
+			explanation.MEGroup = &types.MEGroupExplanation{
+				Name: meGroupName, Hash: meGroupHash, Unrestricted: unrestricted,
+			}
+			if selectedFeatureFlag != nil {
+				explanation.MEGroup.SelectedFeatureKey = selectedFeatureFlag.GetFeatureKey()
+			}
+		}
+	}
+	logging.Debug(
+		"RETURN: variationEvaluator.isFFUnrestrictedByMEGroup(visitor, visitorCode: %s, featureFlag: %s)"+
+			" -> (unrestricted: %s)", visitorCode, featureFlag, unrestricted,
+	)
+	return unrestricted
+}
+
+func (e *variationEvaluator) isVisitorNotInHoldout(
+	visitor storage.Visitor, visitorCode string, track, save bool, bucketingCustomDataIndex *int,
+	explanation *types.VariationExplanation,
+) (isNotInHoldout bool, err error) {
+	holdout := e.dataManager.DataFile().Holdout()
+	isNotInHoldout = true
+	if holdout == nil {
+		return
+	}
+	logging.Debug(
+		"CALL: variationEvaluator.isVisitorNotInHoldout(visitor, visitorCode: %s, track: %s, save: %s,"+
+			" bucketingCustomDataIndex: %s)", visitorCode, track, save, bucketingCustomDataIndex,
+	)
+	consent, blockingBehaviour := e.getConsentAndBlockingBehaviour(visitor)
+	if consent == types.LegalConsentNotGiven && blockingBehaviour == types.CompletelyBlockedByConsent {
+		if explanation != nil {
+			explanation.Holdout = &types.HoldoutExplanation{ExperimentId: holdout.ExperimentId, BlockedByConsent: true}
+		}
+		err = errs.NewFeatureEnvironmentDisabledWithMessage("Evaluation for a holdout is blocked because visitor's consent was not provided.")
+		return
+	}
+	const inHoldoutVariationKey = "in-holdout"
+	codeForHash := getCodeForHash(visitor, visitorCode, bucketingCustomDataIndex)
+	variationHash := utils.ObtainHash(codeForHash, holdout.ExperimentId)
+	logging.Debug("Calculated holdout hash %s for code %s", variationHash, codeForHash)
+	if explanation != nil {
+		explanation.Holdout = &types.HoldoutExplanation{ExperimentId: holdout.ExperimentId, Hash: variationHash}
+	}
+	if varByExp := holdout.GetVariationByHash(variationHash); varByExp != nil {
+		isNotInHoldout = varByExp.VariationKey != inHoldoutVariationKey
+		if explanation != nil {
+			explanation.Holdout.VariationKey = varByExp.VariationKey
+			explanation.Holdout.InHoldout = !isNotInHoldout
+		}
+		if save {
+			evalExp := &evaluatedExperiment{
+				varByExp:   varByExp,
+				experiment: holdout,
+				ruleType:   types.RuleTypeExperimentation,
+			}
+			e.saveVariation(visitorCode, evalExp, track)
+		}
+	}
+	logging.Debug(
+		"RETURN: variationEvaluator.isVisitorNotInHoldout(visitor, visitorCode: %s, track: %s, save: %s,"+
+			" bucketingCustomDataIndex: %s) -> (isNotInHoldout: %s)",
+		visitorCode, track, save, bucketingCustomDataIndex, isNotInHoldout,
+	)
+	return
+}
+
+// checkRuleTargeting checks the targeting of the rule, the evaluation is traced if the rule is explained.
+func (e *variationEvaluator) checkRuleTargeting(visitorCode string, rule types.IRule, re ruleExplanation) bool {
+	experimentId := rule.GetRuleBase().ExperimentId
+	if re.e == nil {
+		return e.targetingManager.CheckTargeting(visitorCode, experimentId, rule.GetTargetingSegment())
+	}
+	re.e.Targeting = e.targetingManager.TraceTargeting(visitorCode, experimentId, rule.GetTargetingSegment())
+	re.e.Targeted = re.e.Targeting.Result
+	return re.e.Targeted
+}
@@ -0,0 +1,110 @@
+package kameleoon
+
+import (
+	"github.com/Kameleoon/client-go/v3/errs"
+	"github.com/Kameleoon/client-go/v3/logging"
+	"github.com/Kameleoon/client-go/v3/types"
+	"github.com/Kameleoon/client-go/v3/utils"
+)
+
+func (c *kameleoonClient) ExplainVariation(
+	visitorCode string, featureKey string,
+) (explanation *types.VariationExplanation, err error) {
+	logging.Info("CALL: kameleoonClient.ExplainVariation(visitorCode: %s, featureKey: %s)", visitorCode, featureKey)
+	defer func() {
+		logging.Info(
+			"RETURN: kameleoonClient.ExplainVariation(visitorCode: %s, featureKey: %s) -> "+
+				"(explanation: %+v, err: %s)", visitorCode, featureKey, explanation, err,
+		)
+	}()
+	if err = utils.ValidateVisitorCode(visitorCode); err != nil {
+		return
+	}
+	var featureFlag types.IFeatureFlag
+	featureFlag, err = c.dataManager.DataFile().GetFeatureFlag(featureKey)
+	if _, notFound := err.(*errs.FeatureNotFound); notFound {
+		return
+	}
+	explanation = &types.VariationExplanation{
+		VisitorCode:        visitorCode,
+		FeatureKey:         featureKey,
+		VariationKey:       string(types.VariationOff),
+		EnvironmentEnabled: featureFlag.GetEnvironmentEnabled(),
+	}
+	visitor := c.visitorManager.GetVisitor(visitorCode)
+	explanation.BucketingKey, explanation.BucketingKeySource =
+		getCodeForHashWithSource(visitor, visitorCode, featureFlag.GetBucketingCustomDataIndex())
+	consent, blockingBehaviour := c.evaluator.getConsentAndBlockingBehaviour(visitor)
+	explanation.LegalConsent = legalConsentLiteral(consent)
+	explanation.ConsentBlocking = consentBlockingBehaviourLiteral(blockingBehaviour)
+	if err != nil {
+		// The feature flag is disabled in the environment
+		return
+	}
+	// Neither tracked nor saved, so the explanation has no side effects
+	var evalExp *evaluatedExperiment
+	if evalExp, err = c.evaluator.evaluate(visitor, visitorCode, featureFlag, false, false, explanation); err != nil {
+		return
+	}
+	explanation.VariationKey = c.evaluator.calculateVariationKey(evalExp, featureFlag.GetDefaultVariationKey())
+	return
+}
+
+func legalConsentLiteral(consent types.LegalConsent) string {
+	switch consent {
+	case types.LegalConsentGiven:
+		return "given"
+	case types.LegalConsentNotGiven:
+		return "not_given"
+	}
+	return "unknown"
+}
+
+func consentBlockingBehaviourLiteral(behaviour types.ConsentBlockingBehaviour) string {
+	if behaviour == types.CompletelyBlockedByConsent {
+		return types.CompletelyBlockedByConsentStr
+	}
+	return types.PartiallyBlockedByConsentStr
+}
+
+// ruleExplanation records the evaluation of a rule, all its methods do nothing if there is no explanation.
+type ruleExplanation struct {
+	e *types.RuleExplanation
+}
+
+func explainRule(explanation *types.VariationExplanation, rule types.IRule) ruleExplanation {
+	if explanation == nil {
+		return ruleExplanation{}
+	}
+	ruleBase := rule.GetRuleBase()
+	e := &types.RuleExplanation{
+		RuleId:       ruleBase.Id,
+		ExperimentId: ruleBase.ExperimentId,
+		Type:         ruleBase.Type.String(),
+		SegmentId:    ruleBase.SegmentId,
+		Exposition:   ruleBase.Exposition,
+	}
+	explanation.Rules = append(explanation.Rules, e)
+	return ruleExplanation{e: e}
+}
+
+func (re ruleExplanation) setExpositionHash(hash float64) {
+	if re.e != nil {
+		re.e.ExpositionHash = &hash
+	}
+}
+
+func (re ruleExplanation) setVariationHash(hash float64) {
+	if re.e != nil {
+		re.e.VariationHash = &hash
+	}
+}
+
+func (re ruleExplanation) setOutcome(outcome types.RuleOutcome, variation *types.VariationByExposition) {
+	if re.e != nil {
+		re.e.Outcome = outcome
+		if variation != nil {
+			re.e.VariationKey = variation.VariationKey
+		}
+	}
+}